@@ -0,0 +1,132 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	godotenv "github.com/joho/godotenv"
+
+	"github.com/aopontann/niji-tuu/internal/common/classifier"
+	"github.com/aopontann/niji-tuu/internal/common/db"
+	"github.com/aopontann/niji-tuu/internal/common/youtube"
+	yt "google.golang.org/api/youtube/v3"
+)
+
+// 歌動画の判定結果からナイーブベイズ分類器を学習し、評価結果を表示するコマンドラインツール
+//
+//	go run ./cmd/classifier -out song_model.json
+//
+// 評価用に分割したデータで精度を表示した後、全てのデータで学習し直したモデルを保存する
+// 学習データは人が判定した結果のみ使う　-auto-negatives を指定した場合は、歌動画ではないデータの不足分を
+// 告知していない動画のうち、現在のルールのスコアが MAYBE_SONG_SCORE_THRESHOLD 未満のものから補う
+func main() {
+	out := flag.String("out", "song_model.json", "モデルの保存先")
+	n := flag.Int("n", 2, "n-gram の最大文字数")
+	testRatio := flag.Float64("test", 0.2, "評価用に分割するデータの割合")
+	seed := flag.Int64("seed", 1, "データを分割する乱数のシード")
+	threshold := flag.Float64("threshold", 0.5, "歌動画と判定する確率の閾値")
+	autoNegatives := flag.Bool("auto-negatives", false, "歌動画ではないデータの不足分を、告知していない動画から補う")
+	flag.Parse()
+
+	if os.Getenv("ENV") != "prod" {
+		godotenv.Load(".env.dev")
+	}
+
+	cdb, err := db.NewDB(os.Getenv("DSN"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer cdb.Close()
+
+	labels, err := cdb.GetTrainingLabels()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	var samples []youtube.LabeledTitle
+	songs := 0
+	for _, l := range labels {
+		samples = append(samples, youtube.LabeledTitle{Title: l.Title, IsSong: l.IsSong})
+		if l.IsSong {
+			songs++
+		}
+	}
+
+	auto := 0
+	if shortage := songs - (len(labels) - songs); *autoNegatives && shortage > 0 {
+		titles, err := negativeTitles(cdb, shortage)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		for _, title := range titles {
+			samples = append(samples, youtube.LabeledTitle{Title: title, IsSong: false})
+		}
+		auto = len(titles)
+	}
+	fmt.Printf("samples: %d (song %d, not song %d)\n", len(samples), songs, len(samples)-songs)
+	fmt.Printf("sources: song_labels %d (song %d, not song %d), auto not song %d\n", len(labels), songs, len(labels)-songs, auto)
+
+	train, test := youtube.SplitSamples(samples, *testRatio, *seed)
+	m := youtube.NewSongModel(*n)
+	for _, s := range train {
+		m.Train(s.Title, s.IsSong)
+	}
+
+	e := m.Evaluate(test, *threshold)
+	fmt.Printf("train: %d, test: %d\n", len(train), len(test))
+	fmt.Printf("TP %d  FP %d  FN %d  TN %d\n", e.TP, e.FP, e.FN, e.TN)
+	fmt.Printf("precision %.3f  recall %.3f  accuracy %.3f\n", e.Precision(), e.Recall(), e.Accuracy())
+
+	m = youtube.NewSongModel(*n)
+	for _, s := range samples {
+		m.Train(s.Title, s.IsSong)
+	}
+	if err := m.Save(*out); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("saved %s (%d n-grams)\n", *out, len(m.Counts))
+}
+
+// 告知していない動画から、歌動画ではない学習データのタイトルを最大 n 件取得する
+// 告知していない歌動画を含めないように、現在のルールのスコアが MAYBE_SONG_SCORE_THRESHOLD 未満の動画のみ使う
+// videos テーブルにはタイトルと動画時間しかないため、それ以外のルールは一致しない
+func negativeTitles(cdb *db.DB, n int) ([]string, error) {
+	maybeThreshold, err := classifier.Threshold("MAYBE_SONG_SCORE_THRESHOLD", classifier.DefaultMaybeSongThreshold)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := cdb.GetSongRules()
+	if err != nil {
+		return nil, err
+	}
+	// 学習するモデル自身のスコアは使わない
+	c := classifier.NewClassifier(rules)
+
+	// 絞り込みで減る分を見込んで多めに取得する
+	videos, err := cdb.GetNegativeCandidates(n * 5)
+	if err != nil {
+		return nil, err
+	}
+
+	var titles []string
+	for _, v := range videos {
+		res := c.Classify(yt.Video{
+			Id:             v.ID,
+			Snippet:        &yt.VideoSnippet{Title: v.Title},
+			ContentDetails: &yt.VideoContentDetails{Duration: v.Duration},
+		})
+		if res.Score >= maybeThreshold {
+			continue
+		}
+		titles = append(titles, v.Title)
+		if len(titles) == n {
+			break
+		}
+	}
+	return titles, nil
+}
@@ -41,6 +41,7 @@ func main() {
 	}
 
 	http.HandleFunc("/", discordbot.Handler)
+	http.HandleFunc("/worker", discordbot.WorkerHandler)
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -15,4 +15,7 @@ func main() {
 	if mode == "-bc" {
 		discordcli.BulkCommand()
 	}
+	if mode == "-dc" {
+		discordcli.DiffCommand()
+	}
 }
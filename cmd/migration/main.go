@@ -24,6 +24,18 @@ func main() {
 		(*db.Video)(nil),
 		(*db.User)(nil),
 		(*db.Keyword)(nil),
+		(*db.Subscription)(nil),
+		(*db.Reschedule)(nil),
+		(*db.ScheduledTask)(nil),
+		(*db.PendingTask)(nil),
+		(*db.Notification)(nil),
+		(*db.NotifyTarget)(nil),
+		(*db.SongRule)(nil),
+		(*db.SongLabel)(nil),
+		(*db.Panel)(nil),
+		(*db.Reminder)(nil),
+		(*db.AuditLog)(nil),
+		(*db.CloudTask)(nil),
 	}
 
 	data := modelsToByte(bundb, models)
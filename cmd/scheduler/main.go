@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	godotenv "github.com/joho/godotenv"
+
+	"github.com/aopontann/niji-tuu/internal/common/db"
+	"github.com/aopontann/niji-tuu/internal/common/task"
+)
+
+// Cloud Tasks を使わずに、DBに登録したタスクを実行するワーカー
+func main() {
+	// Cloud Logging用のログ設定
+	ops := slog.HandlerOptions{
+		AddSource: true,
+		Level:     slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.LevelKey {
+				a.Key = "severity"
+				level := a.Value.Any().(slog.Level)
+				if level == slog.LevelWarn {
+					a.Value = slog.StringValue("WARNING")
+				}
+			}
+
+			return a
+		},
+	}
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, &ops))
+	slog.SetDefault(logger)
+
+	if os.Getenv("ENV") != "prod" {
+		slog.Debug("Loading environmental variables...")
+		if err := godotenv.Load(".env.dev"); err != nil {
+			slog.Error("failed to load env variables: " + err.Error())
+			return
+		}
+	}
+
+	cdb, err := db.NewDB(os.Getenv("DSN"))
+	if err != nil {
+		slog.Error(err.Error())
+		return
+	}
+	defer cdb.Close()
+	ltask := task.NewLocalTask(cdb)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	slog.Debug("Starting scheduler worker...")
+	if err := ltask.Run(ctx, 10*time.Second); err != nil && err != context.Canceled {
+		slog.Error(err.Error())
+	}
+}
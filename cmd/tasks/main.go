@@ -0,0 +1,54 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+
+	godotenv "github.com/joho/godotenv"
+
+	"github.com/aopontann/niji-tuu/internal/common/db"
+	"github.com/aopontann/niji-tuu/internal/common/task"
+)
+
+// Cloud Tasks のタスクを管理するコマンドラインツール
+// -import: キューに残っているタスクのうち、cloud_tasks テーブルに記録されていないタスクを記録する
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "-import" {
+		slog.Error("usage: tasks -import")
+		os.Exit(1)
+	}
+
+	if os.Getenv("ENV") != "prod" {
+		if err := godotenv.Load(".env.dev"); err != nil {
+			slog.Error("failed to load env variables: " + err.Error())
+			return
+		}
+	}
+
+	cdb, err := db.NewDB(os.Getenv("DSN"))
+	if err != nil {
+		slog.Error(err.Error())
+		return
+	}
+	defer cdb.Close()
+	ctask, err := task.NewTask(cdb)
+	if err != nil {
+		slog.Error(err.Error())
+		return
+	}
+	defer ctask.Close()
+
+	for _, queueID := range []string{os.Getenv("DISCORD_QUEUE_ID"), os.Getenv("SONG_QUEUE_ID")} {
+		count, err := ctask.ImportTasks(queueID)
+		if err != nil {
+			slog.Error(err.Error(),
+				slog.String("queue_id", queueID),
+			)
+			continue
+		}
+		slog.Info("import-tasks",
+			slog.String("queue_id", queueID),
+			slog.Int("count", count),
+		)
+	}
+}
@@ -83,7 +83,7 @@ require (
 	golang.org/x/net v0.30.0 // indirect
 	golang.org/x/oauth2 v0.23.0 // indirect
 	golang.org/x/sys v0.30.0 // indirect
-	golang.org/x/text v0.19.0 // indirect
-	google.golang.org/grpc v1.67.1 // indirect
+	golang.org/x/text v0.19.0
+	google.golang.org/grpc v1.67.1
 	google.golang.org/protobuf v1.35.1
 )
@@ -0,0 +1,220 @@
+package classifier
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aopontann/niji-tuu/internal/common/db"
+	"github.com/aopontann/niji-tuu/internal/common/normalize"
+	"github.com/aopontann/niji-tuu/internal/common/youtube"
+	yt "google.golang.org/api/youtube/v3"
+)
+
+// song_rules テーブルに登録できるルールの種類
+const (
+	// タイトルに value を含む
+	KindTitle = "title"
+	// 概要欄に value を含む
+	KindDescription = "description"
+	// タグに value を含む
+	KindTag = "tag"
+	// カテゴリIDが value と一致する 例 10 (Music)
+	KindCategory = "category"
+	// 動画時間が value の範囲内 秒数を 60-420 の形式で指定する　片方を省略した場合は上限、下限なし
+	KindDuration = "duration"
+	// プレミア公開の動画
+	KindPremiere = "premiere"
+	// 生放送
+	KindLive = "live"
+	// SONG_MODEL_PATH のナイーブベイズ分類器　歌動画の確率 p から weight * (2p - 1) を加算する
+	// 無効なルールを登録してあるため、モデルを使う場合は enabled を true にする
+	KindModel = "model"
+)
+
+// 閾値の既定値　既定のルールでは従来の判定と同じ結果になる
+const (
+	DefaultSongThreshold      = 1.0
+	DefaultMaybeSongThreshold = 0.0
+)
+
+// song_rules テーブルが空の場合に使うルール
+var DefaultRules = []db.SongRule{
+	{Kind: KindTitle, Value: "cover", Weight: 1},
+	{Kind: KindTitle, Value: "歌って", Weight: 1},
+	{Kind: KindTitle, Value: "歌わせて", Weight: 1},
+	{Kind: KindTitle, Value: "Original Song", Weight: 1},
+	{Kind: KindTitle, Value: "オリジナル曲", Weight: 1},
+	{Kind: KindTitle, Value: "オリジナル楽曲", Weight: 1},
+	{Kind: KindTitle, Value: "オリジナルソング", Weight: 1},
+	{Kind: KindTitle, Value: "MV", Weight: 1},
+	{Kind: KindTitle, Value: "Music Video", Weight: 1},
+	{Kind: KindTag, Value: "cover", Weight: 1},
+	{Kind: KindTag, Value: "歌って", Weight: 1},
+	{Kind: KindTag, Value: "歌わせて", Weight: 1},
+	{Kind: KindTag, Value: "Original Song", Weight: 1},
+	{Kind: KindTag, Value: "オリジナル曲", Weight: 1},
+	{Kind: KindTag, Value: "オリジナル楽曲", Weight: 1},
+	{Kind: KindTag, Value: "オリジナルソング", Weight: 1},
+	{Kind: KindTag, Value: "MV", Weight: 1},
+	{Kind: KindTag, Value: "Music Video", Weight: 1},
+	{Kind: KindTitle, Value: "切り抜き", Weight: -10},
+	{Kind: KindTitle, Value: "ラジオ", Weight: -10},
+	{Kind: KindTitle, Value: "くろなん", Weight: -10},
+}
+
+// 重み付きのルールから歌動画らしさを判定する
+type Classifier struct {
+	Rules []db.SongRule
+	// 学習済みのモデル　nil の場合は model のルールを無視する
+	Model *youtube.SongModel
+}
+
+// 判定結果
+type Result struct {
+	Score float64
+	// 一致したルールの説明 例 title:cover +1
+	Reasons []string
+}
+
+func NewClassifier(rules []db.SongRule) *Classifier {
+	if len(rules) == 0 {
+		rules = DefaultRules
+	}
+	return &Classifier{Rules: rules}
+}
+
+// SONG_MODEL_PATH の学習済みモデル　起動後に初めて使うときに一度だけ読み込む
+var loadModel = sync.OnceValues(func() (*youtube.SongModel, error) {
+	path := os.Getenv("SONG_MODEL_PATH")
+	if path == "" {
+		return nil, nil
+	}
+	return youtube.LoadSongModel(path)
+})
+
+// DBに登録されているルールから Classifier を作成する
+// SONG_MODEL_PATH が設定されている場合は学習済みのモデルも使う
+func NewClassifierFromDB(cdb *db.DB) (*Classifier, error) {
+	rules, err := cdb.GetSongRules()
+	if err != nil {
+		return nil, err
+	}
+	c := NewClassifier(rules)
+
+	c.Model, err = loadModel()
+	if err != nil {
+		return nil, err
+	}
+	// モデルは model のルールの重みでスコアに加算するため、ルールがないと使われない
+	if c.Model != nil && !c.hasModelRule() {
+		slog.Warn("SONG_MODEL_PATH が設定されていますが、有効な model のルールがないため判定に使いません")
+	}
+	return c, nil
+}
+
+func (c *Classifier) hasModelRule() bool {
+	return slices.ContainsFunc(c.Rules, func(r db.SongRule) bool { return r.Kind == KindModel })
+}
+
+// 一致したルールの重みを合計したスコアと、その内訳を返す
+func (c *Classifier) Classify(v yt.Video) *Result {
+	res := &Result{}
+	for _, r := range c.Rules {
+		if r.Kind == KindModel {
+			if c.Model == nil || v.Snippet == nil {
+				continue
+			}
+			p := c.Model.Probability(v.Snippet.Title)
+			w := r.Weight * (2*p - 1)
+			res.Score += w
+			res.Reasons = append(res.Reasons, fmt.Sprintf("model:%.2f %+g", p, w))
+			continue
+		}
+		if !matchRule(r, v) {
+			continue
+		}
+		res.Score += r.Weight
+		reason := fmt.Sprintf("%s %+g", r.Kind, r.Weight)
+		if r.Value != "" {
+			reason = fmt.Sprintf("%s:%s %+g", r.Kind, r.Value, r.Weight)
+		}
+		res.Reasons = append(res.Reasons, reason)
+	}
+	return res
+}
+
+func matchRule(r db.SongRule, v yt.Video) bool {
+	if v.Snippet == nil {
+		return false
+	}
+
+	switch r.Kind {
+	case KindTitle:
+		return normalize.Contains(v.Snippet.Title, r.Value)
+	case KindDescription:
+		return normalize.Contains(v.Snippet.Description, r.Value)
+	case KindTag:
+		for _, tag := range v.Snippet.Tags {
+			if normalize.Contains(tag, r.Value) {
+				return true
+			}
+		}
+		return false
+	case KindCategory:
+		return v.Snippet.CategoryId == r.Value
+	case KindDuration:
+		if v.ContentDetails == nil {
+			return false
+		}
+		d, err := youtube.ParseDuration(v.ContentDetails.Duration)
+		if err != nil {
+			return false
+		}
+		return inRange(d, r.Value)
+	case KindPremiere:
+		return v.LiveStreamingDetails != nil && v.ContentDetails != nil && v.ContentDetails.Duration != "P0D"
+	case KindLive:
+		return v.LiveStreamingDetails != nil && v.ContentDetails != nil && v.ContentDetails.Duration == "P0D"
+	}
+	return false
+}
+
+// 動画時間が 60-420 の形式で指定した秒数の範囲内か
+func inRange(d time.Duration, value string) bool {
+	lower, upper, ok := strings.Cut(value, "-")
+	if !ok {
+		return false
+	}
+	if lower != "" {
+		n, err := strconv.Atoi(lower)
+		if err != nil || d < time.Duration(n)*time.Second {
+			return false
+		}
+	}
+	if upper != "" {
+		n, err := strconv.Atoi(upper)
+		if err != nil || d > time.Duration(n)*time.Second {
+			return false
+		}
+	}
+	return true
+}
+
+// 環境変数から閾値を読み込む　未設定の場合は既定値を返す
+func Threshold(key string, def float64) (float64, error) {
+	s := os.Getenv(key)
+	if s == "" {
+		return def, nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("環境変数 %s の値 %q は数値ではありません", key, s)
+	}
+	return v, nil
+}
@@ -0,0 +1,110 @@
+package classifier
+
+import (
+	"testing"
+
+	"github.com/aopontann/niji-tuu/internal/common/db"
+	"github.com/aopontann/niji-tuu/internal/common/youtube"
+	yt "google.golang.org/api/youtube/v3"
+)
+
+func newVideo(title string, tags []string, duration string) yt.Video {
+	return yt.Video{
+		Id: "abc",
+		Snippet: &yt.VideoSnippet{
+			Title:      title,
+			Tags:       tags,
+			CategoryId: "10",
+		},
+		ContentDetails:       &yt.VideoContentDetails{Duration: duration},
+		LiveStreamingDetails: &yt.VideoLiveStreamingDetails{},
+	}
+}
+
+func TestDefaultRules(t *testing.T) {
+	c := NewClassifier(nil)
+
+	cases := []struct {
+		video yt.Video
+		song  bool
+		maybe bool
+	}{
+		{newVideo("【歌ってみた】ヒバナ / 葛葉", nil, "PT4M"), true, false},
+		{newVideo("ヒバナ", []string{"cover"}, "PT4M"), true, false},
+		{newVideo("【3D】ヒバナ", nil, "PT4M"), false, true},
+		{newVideo("【切り抜き】歌ってみた振り返り", nil, "PT4M"), false, false},
+	}
+	for _, tc := range cases {
+		res := c.Classify(tc.video)
+		song := res.Score >= DefaultSongThreshold
+		maybe := !song && res.Score >= DefaultMaybeSongThreshold
+		if song != tc.song || maybe != tc.maybe {
+			t.Errorf("%s: score %v %v, want song=%v maybe=%v", tc.video.Snippet.Title, res.Score, res.Reasons, tc.song, tc.maybe)
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	c := NewClassifier([]db.SongRule{
+		{Kind: KindCategory, Value: "10", Weight: 0.5},
+		{Kind: KindDuration, Value: "60-420", Weight: 0.5},
+		{Kind: KindDuration, Value: "3600-", Weight: -1},
+		{Kind: KindPremiere, Weight: 0.25},
+		{Kind: KindLive, Weight: -2},
+		{Kind: KindDescription, Value: "作詞", Weight: 1},
+	})
+
+	v := newVideo("ヒバナ", nil, "PT3M30S")
+	v.Snippet.Description = "作詞・作曲 DECO*27"
+	res := c.Classify(v)
+	if res.Score != 2.25 {
+		t.Errorf("score = %v, want 2.25 %v", res.Score, res.Reasons)
+	}
+	if len(res.Reasons) != 4 || res.Reasons[0] != "category:10 +0.5" || res.Reasons[2] != "premiere +0.25" {
+		t.Errorf("unexpected reasons %v", res.Reasons)
+	}
+
+	res = c.Classify(newVideo("雑談", nil, "P0D"))
+	if res.Score != -1.5 {
+		t.Errorf("score = %v, want -1.5 %v", res.Score, res.Reasons)
+	}
+}
+
+func TestModelRule(t *testing.T) {
+	m := youtube.NewSongModel(2)
+	m.Train("【歌ってみた】ヒバナ", true)
+	m.Train("【APEX】ランクマッチ", false)
+
+	rules := []db.SongRule{{Kind: KindModel, Weight: 2}}
+	v := newVideo("【歌ってみた】KING", nil, "PT4M")
+
+	// モデルを読み込んでいない場合は無視する
+	if res := NewClassifier(rules).Classify(v); res.Score != 0 {
+		t.Errorf("score = %v, want 0", res.Score)
+	}
+
+	c := &Classifier{Rules: rules, Model: m}
+	res := c.Classify(v)
+	if res.Score <= 0 || res.Score > 2 || len(res.Reasons) != 1 {
+		t.Errorf("unexpected result %v %v", res.Score, res.Reasons)
+	}
+
+	if !c.hasModelRule() || NewClassifier(nil).hasModelRule() {
+		t.Error("hasModelRule() returned unexpected result")
+	}
+}
+
+func TestThreshold(t *testing.T) {
+	t.Setenv("SONG_SCORE_THRESHOLD", "")
+	if v, err := Threshold("SONG_SCORE_THRESHOLD", 1); err != nil || v != 1 {
+		t.Errorf("Threshold() = %v %v, want 1", v, err)
+	}
+	t.Setenv("SONG_SCORE_THRESHOLD", "2.5")
+	if v, err := Threshold("SONG_SCORE_THRESHOLD", 1); err != nil || v != 2.5 {
+		t.Errorf("Threshold() = %v %v, want 2.5", v, err)
+	}
+	t.Setenv("SONG_SCORE_THRESHOLD", "high")
+	if _, err := Threshold("SONG_SCORE_THRESHOLD", 1); err == nil {
+		t.Error("Threshold() expected error")
+	}
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"database/sql"
 	"log/slog"
 	"slices"
 	"strings"
@@ -22,6 +23,7 @@ type Vtuber struct {
 	Name              string    `bun:"name,notnull,type:varchar"`
 	ItemCount         int64     `bun:"item_count,default:0,type:integer"`
 	PlaylistLatestUrl string    `bun:"playlist_latest_url,type:varchar,default:''"`
+	Groups            []string  `bun:"groups,array"`
 	CreatedAt         time.Time `bun:"created_at,type:TIMESTAMP(0),nullzero,notnull,default:CURRENT_TIMESTAMP"`
 	UpdatedAt         time.Time `bun:"updated_at,type:TIMESTAMP(0),nullzero,notnull,default:CURRENT_TIMESTAMP"`
 }
@@ -51,15 +53,211 @@ type User struct {
 type Keyword struct {
 	bun.BaseModel `bun:"table:keywords"`
 
-	Name      string    `bun:"name,type:varchar(100),pk"`
-	RoleID    string    `bun:"role_id,type:varchar(19),notnull"`
-	ChannelID string    `bun:"channel_id,type:varchar(30)"`
-	Include   []string  `bun:"include,array"`
-	Ignore    []string  `bun:"ignore,array"`
+	Name            string    `bun:"name,type:varchar(100),pk"`
+	RoleID          string    `bun:"role_id,type:varchar(19),notnull"`
+	ChannelID       string    `bun:"channel_id,type:varchar(30)"`
+	Include         []string  `bun:"include,array"`
+	Ignore          []string  `bun:"ignore,array"`
+	Scopes          []string  `bun:"scopes,array"`
+	AllowChannels   []string  `bun:"allow_channels,array"`
+	DenyChannels    []string  `bun:"deny_channels,array"`
+	AllowGroups     []string  `bun:"allow_groups,array"`
+	DenyGroups      []string  `bun:"deny_groups,array"`
+	SlackWebhookURL string    `bun:"slack_webhook_url,type:varchar,notnull,default:''"`
+	CreatedAt       time.Time `bun:"created_at,type:TIMESTAMP(0),nullzero,notnull,default:CURRENT_TIMESTAMP"`
+	UpdatedAt       time.Time `bun:"updated_at,type:TIMESTAMP(0),nullzero,notnull,default:CURRENT_TIMESTAMP"`
+}
+
+type Subscription struct {
+	bun.BaseModel `bun:"table:subscriptions"`
+
+	ChannelID    string    `bun:"channel_id,type:varchar(24),pk"`
+	LeaseSeconds int64     `bun:"lease_seconds,notnull,default:0,type:integer"`
+	ExpiresAt    time.Time `bun:"expires_at,type:timestamp"`
+	CreatedAt    time.Time `bun:"created_at,type:TIMESTAMP(0),nullzero,notnull,default:CURRENT_TIMESTAMP"`
+	UpdatedAt    time.Time `bun:"updated_at,type:TIMESTAMP(0),nullzero,notnull,default:CURRENT_TIMESTAMP"`
+}
+
+type Reschedule struct {
+	bun.BaseModel `bun:"table:video_reschedules"`
+
+	ID           int64     `bun:"id,pk,autoincrement"`
+	VideoID      string    `bun:"video_id,type:varchar(11),notnull"`
+	OldStartTime time.Time `bun:"old_start_time,type:timestamp"`
+	NewStartTime time.Time `bun:"new_start_time,type:timestamp"`
+	CreatedAt    time.Time `bun:"created_at,type:TIMESTAMP(0),nullzero,notnull,default:CURRENT_TIMESTAMP"`
+}
+
+type ScheduledTask struct {
+	bun.BaseModel `bun:"table:scheduled_tasks"`
+
+	Name         string    `bun:"name,type:varchar(200),pk"`
+	QueueID      string    `bun:"queue_id,type:varchar(100),notnull"`
+	VideoID      string    `bun:"video_id,type:varchar(11),notnull"`
+	MinutesAgo   int64     `bun:"minutes_ago,notnull,default:0,type:integer"`
+	URL          string    `bun:"url,notnull,type:varchar"`
+	ScheduleTime time.Time `bun:"schedule_time,notnull,type:timestamp"`
+	Status       string    `bun:"status,notnull,default:'pending',type:varchar(20)"`
+	Attempts     int64     `bun:"attempts,notnull,default:0,type:integer"`
+	LastError    string    `bun:"last_error,notnull,default:'',type:varchar"`
+	CreatedAt    time.Time `bun:"created_at,type:TIMESTAMP(0),nullzero,notnull,default:CURRENT_TIMESTAMP"`
+	UpdatedAt    time.Time `bun:"updated_at,type:TIMESTAMP(0),nullzero,notnull,default:CURRENT_TIMESTAMP"`
+}
+
+// scheduled_tasks の実行状態
+const (
+	ScheduledTaskStatusPending = "pending"
+	// ワーカーが取得して送信中のタスク
+	ScheduledTaskStatusRunning = "running"
+	ScheduledTaskStatusDone    = "done"
+	ScheduledTaskStatusFailed  = "failed"
+)
+
+type PendingTask struct {
+	bun.BaseModel `bun:"table:pending_tasks"`
+
+	VideoID    string    `bun:"video_id,type:varchar(11),pk"`
+	QueueID    string    `bun:"queue_id,type:varchar(100),pk"`
+	MinutesAgo int64     `bun:"minutes_ago,type:integer,pk"`
+	URL        string    `bun:"url,notnull,type:varchar"`
+	StartTime  time.Time `bun:"scheduled_start_time,type:timestamp"`
+	CreatedAt  time.Time `bun:"created_at,type:TIMESTAMP(0),nullzero,notnull,default:CURRENT_TIMESTAMP"`
+	UpdatedAt  time.Time `bun:"updated_at,type:TIMESTAMP(0),nullzero,notnull,default:CURRENT_TIMESTAMP"`
+}
+
+type Notification struct {
+	bun.BaseModel `bun:"table:notifications"`
+
+	VideoID     string    `bun:"video_id,type:varchar(11),pk"`
+	Target      string    `bun:"target,type:varchar(100),pk"`
+	Kind        string    `bun:"kind,type:varchar(30),pk"`
+	MessageID   string    `bun:"message_id,notnull,default:'',type:varchar(30)"`
+	Status      string    `bun:"status,notnull,type:varchar(20)"`
+	Error       string    `bun:"error,notnull,default:'',type:varchar"`
+	VideoStatus string    `bun:"video_status,notnull,default:'upcoming',type:varchar(20)"`
+	CreatedAt   time.Time `bun:"created_at,type:TIMESTAMP(0),nullzero,notnull,default:CURRENT_TIMESTAMP"`
+	UpdatedAt   time.Time `bun:"updated_at,type:TIMESTAMP(0),nullzero,notnull,default:CURRENT_TIMESTAMP"`
+}
+
+// 通知の種類
+const (
+	NotificationKindSongFCM     = "song-fcm"
+	NotificationKindSongDiscord = "song-discord"
+	NotificationKindKeyword     = "keyword"
+	NotificationKindSongReview  = "song-review"
+	// 歌動画か判別しづらい動画の Webhook などへの通知
+	NotificationKindMaybeSong = "maybe-song"
+)
+
+// 通知の送信状態
+const (
+	NotificationStatusSent   = "sent"
+	NotificationStatusFailed = "failed"
+)
+
+type NotifyTarget struct {
+	bun.BaseModel `bun:"table:notify_targets"`
+
+	ID        int64     `bun:"id,pk,autoincrement"`
+	Scope     string    `bun:"scope,notnull,type:varchar(20)"`
+	Name      string    `bun:"name,notnull,type:varchar(100)"`
+	Type      string    `bun:"type,notnull,type:varchar(30)"`
+	URL       string    `bun:"url,notnull,type:varchar"`
+	Secret    string    `bun:"secret,notnull,default:'',type:varchar"`
+	CreatedAt time.Time `bun:"created_at,type:TIMESTAMP(0),nullzero,notnull,default:CURRENT_TIMESTAMP"`
+	UpdatedAt time.Time `bun:"updated_at,type:TIMESTAMP(0),nullzero,notnull,default:CURRENT_TIMESTAMP"`
+}
+
+type SongRule struct {
+	bun.BaseModel `bun:"table:song_rules"`
+
+	ID        int64     `bun:"id,pk,autoincrement"`
+	Kind      string    `bun:"kind,notnull,type:varchar(20)"`
+	Value     string    `bun:"value,notnull,default:'',type:varchar"`
+	Weight    float64   `bun:"weight,notnull,type:double precision"`
+	Enabled   bool      `bun:"enabled,notnull,default:true"`
 	CreatedAt time.Time `bun:"created_at,type:TIMESTAMP(0),nullzero,notnull,default:CURRENT_TIMESTAMP"`
 	UpdatedAt time.Time `bun:"updated_at,type:TIMESTAMP(0),nullzero,notnull,default:CURRENT_TIMESTAMP"`
 }
 
+// 歌動画か人が判定した結果　分類器の学習データとして使う
+type SongLabel struct {
+	bun.BaseModel `bun:"table:song_labels"`
+
+	VideoID     string    `bun:"video_id,type:varchar(11),pk"`
+	Title       string    `bun:"title,notnull,type:varchar"`
+	Description string    `bun:"description,notnull,default:'',type:varchar"`
+	Tags        []string  `bun:"tags,array"`
+	IsSong      bool      `bun:"is_song,notnull"`
+	UserID      string    `bun:"user_id,notnull,default:'',type:varchar(20)"`
+	CreatedAt   time.Time `bun:"created_at,type:TIMESTAMP(0),nullzero,notnull,default:CURRENT_TIMESTAMP"`
+	UpdatedAt   time.Time `bun:"updated_at,type:TIMESTAMP(0),nullzero,notnull,default:CURRENT_TIMESTAMP"`
+}
+
+// キーワードのロールを選択して登録、解除するパネルのメッセージ
+type Panel struct {
+	bun.BaseModel `bun:"table:panels"`
+
+	MessageID string    `bun:"message_id,type:varchar(20),pk"`
+	ChannelID string    `bun:"channel_id,type:varchar(20),notnull"`
+	CreatedAt time.Time `bun:"created_at,type:TIMESTAMP(0),nullzero,notnull,default:CURRENT_TIMESTAMP"`
+	UpdatedAt time.Time `bun:"updated_at,type:TIMESTAMP(0),nullzero,notnull,default:CURRENT_TIMESTAMP"`
+}
+
+// 登録した通知タスクの記録
+type Reminder struct {
+	bun.BaseModel `bun:"table:reminders"`
+
+	VideoID      string    `bun:"video_id,type:varchar(11),pk"`
+	QueueID      string    `bun:"queue_id,type:varchar(100),pk"`
+	MinutesAgo   int64     `bun:"minutes_ago,type:integer,pk"`
+	URL          string    `bun:"url,notnull,type:varchar"`
+	ScheduleTime time.Time `bun:"schedule_time,notnull,type:TIMESTAMP(0)"`
+	Status       string    `bun:"status,notnull,type:varchar(20)"`
+	CreatedAt    time.Time `bun:"created_at,type:TIMESTAMP(0),nullzero,notnull,default:CURRENT_TIMESTAMP"`
+	UpdatedAt    time.Time `bun:"updated_at,type:TIMESTAMP(0),nullzero,notnull,default:CURRENT_TIMESTAMP"`
+}
+
+// 通知タスクの状態
+const (
+	ReminderStatusScheduled = "scheduled"
+	// Cloud Tasks に登録できる範囲になるまで pending_tasks テーブルに保留しているタスク
+	ReminderStatusDeferred = "deferred"
+	ReminderStatusCanceled = "canceled"
+)
+
+// Cloud Tasks に登録したタスクの名前
+// タスクの削除、置き換え時にキューを検索せずに名前を指定するため
+type CloudTask struct {
+	bun.BaseModel `bun:"table:cloud_tasks"`
+
+	VideoID      string    `bun:"video_id,type:varchar(11),pk"`
+	QueueID      string    `bun:"queue_id,type:varchar(100),pk"`
+	MinutesAgo   int64     `bun:"minutes_ago,type:integer,pk"`
+	Name         string    `bun:"name,notnull,type:varchar"`
+	ScheduleTime time.Time `bun:"schedule_time,notnull,type:TIMESTAMP(0)"`
+	CreatedAt    time.Time `bun:"created_at,type:TIMESTAMP(0),nullzero,notnull,default:CURRENT_TIMESTAMP"`
+	UpdatedAt    time.Time `bun:"updated_at,type:TIMESTAMP(0),nullzero,notnull,default:CURRENT_TIMESTAMP"`
+}
+
+// 権限がなく拒否した操作などの記録
+type AuditLog struct {
+	bun.BaseModel `bun:"table:audit_logs"`
+
+	ID        int64     `bun:"id,pk,autoincrement"`
+	UserID    string    `bun:"user_id,notnull,default:'',type:varchar(20)"`
+	GuildID   string    `bun:"guild_id,notnull,default:'',type:varchar(20)"`
+	Action    string    `bun:"action,notnull,type:varchar(100)"`
+	Result    string    `bun:"result,notnull,type:varchar(20)"`
+	Reason    string    `bun:"reason,notnull,default:'',type:varchar"`
+	CreatedAt time.Time `bun:"created_at,type:TIMESTAMP(0),nullzero,notnull,default:CURRENT_TIMESTAMP"`
+}
+
+// 監査ログの結果
+const (
+	AuditResultRejected = "rejected"
+)
+
 type DB struct {
 	Service *bun.DB
 }
@@ -94,6 +292,35 @@ func (db *DB) GetVtubers() ([]Vtuber, error) {
 	return vtubers, nil
 }
 
+// チャンネルIDからvtuberの名前を取得　登録されていない場合は空文字を返す
+func (db *DB) GetVtuberName(cid string) (string, error) {
+	var name string
+	ctx := context.Background()
+	err := db.Service.NewSelect().Model((*Vtuber)(nil)).Column("name").Where("id = ?", cid).Scan(ctx, &name)
+	if err != nil && err != sql.ErrNoRows {
+		slog.Error(err.Error(),
+			slog.String("channel_id", cid),
+		)
+		return "", err
+	}
+
+	return name, nil
+}
+
+func (db *DB) GetVtuberGroups(cid string) ([]string, error) {
+	var groups []string
+	ctx := context.Background()
+	err := db.Service.NewSelect().Model((*Vtuber)(nil)).Column("groups").Where("id = ?", cid).Scan(ctx, pgdialect.Array(&groups))
+	if err != nil && err != sql.ErrNoRows {
+		slog.Error(err.Error(),
+			slog.String("channel_id", cid),
+		)
+		return nil, err
+	}
+
+	return groups, nil
+}
+
 func (db *DB) UpdateVtubers(vtubers []Vtuber, tx *bun.Tx) error {
 	ctx := context.Background()
 	if len(vtubers) == 0 {
@@ -132,23 +359,28 @@ func (db *DB) PlaylistIDs() ([]string, error) {
 	return pids, nil
 }
 
+// 動画の公開予定時刻を取得　生放送、プレミア公開ではない場合は 1998-01-01 15:04:05 を返す
+func ScheduledStartTime(v youtube.Video) time.Time {
+	scheduledStartTime := "1998-01-01 15:04:05" // 例 2022-03-28T11:00:00Z
+	if v.LiveStreamingDetails != nil {
+		// "2022-03-28 11:00:00"形式に変換
+		rep1 := strings.Replace(v.LiveStreamingDetails.ScheduledStartTime, "T", " ", 1)
+		scheduledStartTime = strings.Replace(rep1, "Z", "", 1)
+	}
+	t, _ := time.Parse("2006-01-02 15:04:05", scheduledStartTime)
+	return t
+}
+
 // 動画情報をDBに登録　登録済みの動画は無視する
 func (db *DB) SaveVideos(videos []youtube.Video, tx *bun.Tx) error {
 	var Videos []Video
 	for _, v := range videos {
-		scheduledStartTime := "1998-01-01 15:04:05" // 例 2022-03-28T11:00:00Z
-		if v.LiveStreamingDetails != nil {
-			// "2022-03-28 11:00:00"形式に変換
-			rep1 := strings.Replace(v.LiveStreamingDetails.ScheduledStartTime, "T", " ", 1)
-			scheduledStartTime = strings.Replace(rep1, "Z", "", 1)
-		}
-		t, _ := time.Parse("2006-01-02 15:04:05", scheduledStartTime)
 		Videos = append(Videos, Video{
 			ID:        v.Id,
 			Title:     v.Snippet.Title,
 			Duration:  v.ContentDetails.Duration,
 			Content:   v.Snippet.LiveBroadcastContent,
-			StartTime: t,
+			StartTime: ScheduledStartTime(v),
 			UpdatedAt: time.Now(),
 		})
 	}
@@ -173,6 +405,80 @@ func (db *DB) SaveVideos(videos []youtube.Video, tx *bun.Tx) error {
 	)
 }
 
+// 公開予定の動画リストを取得
+func (db *DB) GetUpcomingVideos() ([]Video, error) {
+	var videos []Video
+	ctx := context.Background()
+	err := db.Service.NewSelect().Model(&videos).Where("content = ?", "upcoming").Scan(ctx)
+	if err != nil {
+		slog.Error(err.Error())
+		return nil, err
+	}
+
+	return videos, nil
+}
+
+// 指定時刻以降に登録された動画を新しい順に取得
+func (db *DB) GetVideosSince(since time.Time) ([]Video, error) {
+	var videos []Video
+	ctx := context.Background()
+	err := db.Service.NewSelect().
+		Model(&videos).
+		Where("created_at >= ?", since).
+		Order("created_at DESC").
+		Scan(ctx)
+	if err != nil {
+		slog.Error(err.Error())
+		return nil, err
+	}
+
+	return videos, nil
+}
+
+// 動画のタイトル、配信状態、公開予定時刻を更新
+func (db *DB) UpdateVideos(videos []Video, tx *bun.Tx) error {
+	ctx := context.Background()
+	if len(videos) == 0 {
+		return nil
+	}
+
+	return retry.Do(
+		func() error {
+			var err error
+			if tx != nil {
+				_, err = tx.NewUpdate().Model(&videos).Column("title", "content", "scheduled_start_time", "updated_at").Bulk().Exec(ctx)
+			} else {
+				_, err = db.Service.NewUpdate().Model(&videos).Column("title", "content", "scheduled_start_time", "updated_at").Bulk().Exec(ctx)
+			}
+			return err
+		},
+		retry.Attempts(3),
+		retry.Delay(1*time.Second),
+	)
+}
+
+// 公開予定時刻の変更履歴を登録
+func (db *DB) SaveReschedules(reschedules []Reschedule, tx *bun.Tx) error {
+	ctx := context.Background()
+	if len(reschedules) == 0 {
+		return nil
+	}
+
+	return retry.Do(
+		func() error {
+			var err error
+			if tx != nil {
+				_, err = tx.NewInsert().Model(&reschedules).Exec(ctx)
+			} else {
+				_, err = db.Service.NewInsert().Model(&reschedules).Exec(ctx)
+			}
+			return err
+		},
+		retry.Attempts(3),
+		retry.Delay(1*time.Second),
+	)
+}
+
 // DBに登録されていない動画リストのみフィルター
 func (db *DB) NotExistsVideoID(vids []string) ([]string, error) {
 	ctx := context.Background()
@@ -221,4 +527,598 @@ func (db *DB) GetKeywords() ([]Keyword, error) {
 		return nil, err
 	}
 	return keywords, nil
-}
\ No newline at end of file
+}
+
+func (db *DB) GetKeyword(name string) (*Keyword, error) {
+	ctx := context.Background()
+	var keyword Keyword
+	err := db.Service.NewSelect().Model(&keyword).Where("name = ?", name).Scan(ctx)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			slog.Error(err.Error(),
+				slog.String("keyword", name),
+			)
+		}
+		return nil, err
+	}
+	return &keyword, nil
+}
+
+// キーワードの指定したカラムを更新する
+func (db *DB) UpdateKeyword(keyword *Keyword, columns ...string) error {
+	ctx := context.Background()
+	keyword.UpdatedAt = time.Now()
+	columns = append(columns, "updated_at")
+	return retry.Do(
+		func() error {
+			_, err := db.Service.NewUpdate().Model(keyword).Column(columns...).WherePK().Exec(ctx)
+			return err
+		},
+		retry.Attempts(3),
+		retry.Delay(1*time.Second),
+	)
+}
+
+// キーワードの名前を変更する　キーワードごとに設定された通知先の名前も変更する
+func (db *DB) RenameKeyword(oldName string, newName string) error {
+	ctx := context.Background()
+	return db.Service.RunInTx(ctx, &sql.TxOptions{}, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewUpdate().
+			Model((*Keyword)(nil)).
+			Set("name = ?", newName).
+			Set("updated_at = ?", time.Now()).
+			Where("name = ?", oldName).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		// scope は notifier.ScopeKeyword
+		_, err = tx.NewUpdate().
+			Model((*NotifyTarget)(nil)).
+			Set("name = ?", newName).
+			Set("updated_at = ?", time.Now()).
+			Where("scope = ?", "keyword").
+			Where("name = ?", oldName).
+			Exec(ctx)
+		return err
+	})
+}
+
+// キーワードを削除する　キーワードごとに設定された通知先も削除する
+func (db *DB) DeleteKeyword(name string) error {
+	ctx := context.Background()
+	err := db.Service.RunInTx(ctx, &sql.TxOptions{}, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewDelete().Model((*Keyword)(nil)).Where("name = ?", name).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		// scope は notifier.ScopeKeyword
+		_, err = tx.NewDelete().
+			Model((*NotifyTarget)(nil)).
+			Where("scope = ?", "keyword").
+			Where("name = ?", name).
+			Exec(ctx)
+		return err
+	})
+	if err != nil {
+		slog.Error(err.Error(),
+			slog.String("keyword", name),
+		)
+		return err
+	}
+	return nil
+}
+
+// WebSubの購読情報を登録　登録済みの場合は有効期限を更新する
+func (db *DB) SaveSubscription(sub Subscription) error {
+	ctx := context.Background()
+	sub.UpdatedAt = time.Now()
+	return retry.Do(
+		func() error {
+			_, err := db.Service.NewInsert().Model(&sub).
+				On("CONFLICT (channel_id) DO UPDATE").
+				Set("lease_seconds = EXCLUDED.lease_seconds").
+				Set("expires_at = EXCLUDED.expires_at").
+				Set("updated_at = EXCLUDED.updated_at").
+				Exec(ctx)
+			return err
+		},
+		retry.Attempts(3),
+		retry.Delay(1*time.Second),
+	)
+}
+
+// 購読を解除した、またはハブに拒否されたチャンネルの購読情報を削除する
+func (db *DB) DeleteSubscription(cid string) error {
+	ctx := context.Background()
+	return retry.Do(
+		func() error {
+			_, err := db.Service.NewDelete().Model((*Subscription)(nil)).Where("channel_id = ?", cid).Exec(ctx)
+			return err
+		},
+		retry.Attempts(3),
+		retry.Delay(1*time.Second),
+	)
+}
+
+// vtubers テーブルに登録されているチャンネルか
+func (db *DB) ExistsVtuber(cid string) (bool, error) {
+	ctx := context.Background()
+	exists, err := db.Service.NewSelect().Model((*Vtuber)(nil)).Where("id = ?", cid).Exists(ctx)
+	if err != nil {
+		slog.Error(err.Error(),
+			slog.String("channel_id", cid),
+		)
+		return false, err
+	}
+	return exists, nil
+}
+
+// WebSubの購読情報が登録されているチャンネルか
+func (db *DB) ExistsSubscription(cid string) (bool, error) {
+	ctx := context.Background()
+	exists, err := db.Service.NewSelect().Model((*Subscription)(nil)).Where("channel_id = ?", cid).Exists(ctx)
+	if err != nil {
+		slog.Error(err.Error(),
+			slog.String("channel_id", cid),
+		)
+		return false, err
+	}
+	return exists, nil
+}
+
+// 未購読、または指定時刻までに購読期限が切れるチャンネルIDリストを取得
+func (db *DB) ExpiringSubscriptionChannelIDs(before time.Time) ([]string, error) {
+	var cids []string
+	ctx := context.Background()
+	err := db.Service.NewSelect().
+		Model((*Vtuber)(nil)).
+		Column("vtuber.id").
+		Join("LEFT JOIN subscriptions AS s ON s.channel_id = vtuber.id").
+		Where("s.expires_at IS NULL OR s.expires_at < ?", before).
+		Scan(ctx, &cids)
+	if err != nil {
+		slog.Error(err.Error())
+		return nil, err
+	}
+
+	return cids, nil
+}
+
+// 保留中のタスクを登録　登録済みの場合は公開予定時刻とURLを更新する
+func (db *DB) SavePendingTask(pt PendingTask) error {
+	ctx := context.Background()
+	pt.UpdatedAt = time.Now()
+	return retry.Do(
+		func() error {
+			_, err := db.Service.NewInsert().Model(&pt).
+				On("CONFLICT (video_id, queue_id, minutes_ago) DO UPDATE").
+				Set("url = EXCLUDED.url").
+				Set("scheduled_start_time = EXCLUDED.scheduled_start_time").
+				Set("updated_at = EXCLUDED.updated_at").
+				Exec(ctx)
+			return err
+		},
+		retry.Attempts(3),
+		retry.Delay(1*time.Second),
+	)
+}
+
+// 保留中のタスクリストを取得
+func (db *DB) GetPendingTasks() ([]PendingTask, error) {
+	var tasks []PendingTask
+	ctx := context.Background()
+	err := db.Service.NewSelect().Model(&tasks).Order("scheduled_start_time ASC").Scan(ctx)
+	if err != nil {
+		slog.Error(err.Error())
+		return nil, err
+	}
+
+	return tasks, nil
+}
+
+// 保留中のタスクを削除
+func (db *DB) DeletePendingTask(pt PendingTask) error {
+	ctx := context.Background()
+	_, err := db.Service.NewDelete().Model(&pt).WherePK().Exec(ctx)
+	if err != nil {
+		slog.Error(err.Error(),
+			slog.String("video_id", pt.VideoID),
+		)
+		return err
+	}
+	return nil
+}
+
+// 指定したキューに保留中の動画のタスクを全て削除
+func (db *DB) DeletePendingTasks(queueID string, vid string) error {
+	ctx := context.Background()
+	_, err := db.Service.NewDelete().
+		Model((*PendingTask)(nil)).
+		Where("queue_id = ?", queueID).
+		Where("video_id = ?", vid).
+		Exec(ctx)
+	if err != nil {
+		slog.Error(err.Error(),
+			slog.String("video_id", vid),
+		)
+		return err
+	}
+	return nil
+}
+
+// 指定した動画、送信先、種類の通知を送信済みか
+func (db *DB) NotificationSent(vid string, target string, kind string) (bool, error) {
+	ctx := context.Background()
+	exists, err := db.Service.NewSelect().
+		Model((*Notification)(nil)).
+		Where("video_id = ?", vid).
+		Where("target = ?", target).
+		Where("kind = ?", kind).
+		Where("status = ?", NotificationStatusSent).
+		Exists(ctx)
+	if err != nil {
+		slog.Error(err.Error(),
+			slog.String("video_id", vid),
+		)
+		return false, err
+	}
+	return exists, nil
+}
+
+// 通知の送信結果を登録　登録済みの場合は送信結果を更新する
+func (db *DB) SaveNotification(n Notification) error {
+	ctx := context.Background()
+	n.UpdatedAt = time.Now()
+	return retry.Do(
+		func() error {
+			_, err := db.Service.NewInsert().Model(&n).
+				On("CONFLICT (video_id, target, kind) DO UPDATE").
+				Set("message_id = EXCLUDED.message_id").
+				Set("status = EXCLUDED.status").
+				Set("error = EXCLUDED.error").
+				Set("updated_at = EXCLUDED.updated_at").
+				Exec(ctx)
+			return err
+		},
+		retry.Attempts(3),
+		retry.Delay(1*time.Second),
+	)
+}
+
+// 指定時刻以降にDiscordで告知し、配信が終わっていない動画の通知リストを取得
+func (db *DB) GetAnnouncedNotifications(since time.Time) ([]Notification, error) {
+	var notifications []Notification
+	ctx := context.Background()
+	err := db.Service.NewSelect().
+		Model(&notifications).
+		Where("kind IN (?)", bun.In([]string{NotificationKindSongDiscord, NotificationKindKeyword})).
+		Where("status = ?", NotificationStatusSent).
+		Where("message_id != ''").
+		Where("video_status IN (?)", bun.In([]string{"upcoming", "live"})).
+		Where("created_at >= ?", since).
+		Scan(ctx)
+	if err != nil {
+		slog.Error(err.Error())
+		return nil, err
+	}
+
+	return notifications, nil
+}
+
+// 告知した動画の状態を更新
+func (db *DB) UpdateNotificationVideoStatus(n Notification) error {
+	ctx := context.Background()
+	n.UpdatedAt = time.Now()
+	_, err := db.Service.NewUpdate().Model(&n).Column("video_status", "updated_at").WherePK().Exec(ctx)
+	if err != nil {
+		slog.Error(err.Error(),
+			slog.String("video_id", n.VideoID),
+		)
+		return err
+	}
+	return nil
+}
+
+// 動画IDリストから動画情報を取得
+func (db *DB) GetVideos(vids []string) ([]Video, error) {
+	var videos []Video
+	ctx := context.Background()
+	err := db.Service.NewSelect().Model(&videos).Where("id IN (?)", bun.In(vids)).Scan(ctx)
+	if err != nil {
+		slog.Error(err.Error())
+		return nil, err
+	}
+
+	return videos, nil
+}
+
+// キーワード、または機能ごとに設定された通知先リストを取得
+func (db *DB) GetNotifyTargets(scope string, name string) ([]NotifyTarget, error) {
+	var targets []NotifyTarget
+	ctx := context.Background()
+	err := db.Service.NewSelect().
+		Model(&targets).
+		Where("scope = ?", scope).
+		Where("name = ?", name).
+		Scan(ctx)
+	if err != nil {
+		slog.Error(err.Error())
+		return nil, err
+	}
+
+	return targets, nil
+}
+
+func (db *DB) GetSongRules() ([]SongRule, error) {
+	var rules []SongRule
+	ctx := context.Background()
+	err := db.Service.NewSelect().
+		Model(&rules).
+		Where("enabled = ?", true).
+		Order("id ASC").
+		Scan(ctx)
+	if err != nil {
+		slog.Error(err.Error())
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// 判定結果を保存する　同じ動画を判定し直した場合は上書きする
+func (db *DB) SaveSongLabel(l SongLabel) error {
+	ctx := context.Background()
+	l.UpdatedAt = time.Now()
+	return retry.Do(
+		func() error {
+			_, err := db.Service.NewInsert().Model(&l).
+				On("CONFLICT (video_id) DO UPDATE").
+				Set("title = EXCLUDED.title").
+				Set("description = EXCLUDED.description").
+				Set("tags = EXCLUDED.tags").
+				Set("is_song = EXCLUDED.is_song").
+				Set("user_id = EXCLUDED.user_id").
+				Set("updated_at = EXCLUDED.updated_at").
+				Exec(ctx)
+			return err
+		},
+		retry.Attempts(3),
+		retry.Delay(1*time.Second),
+	)
+}
+
+// 分類器の学習データとして、人が判定した結果を取得する
+// 告知を送信した動画は従来のルールで選ばれているため、学習データに含めない
+func (db *DB) GetTrainingLabels() ([]SongLabel, error) {
+	var labels []SongLabel
+	ctx := context.Background()
+	err := db.Service.NewSelect().Model(&labels).Scan(ctx)
+	if err != nil {
+		slog.Error(err.Error())
+		return nil, err
+	}
+	return labels, nil
+}
+
+// 歌動画ではない学習データの候補として、歌みた告知を送信していない公開済みの動画を無作為に取得する
+// 人が判定した動画は除く　歌動画が含まれる場合があるため、呼び出し元で判定のスコアが低いものに絞り込む
+func (db *DB) GetNegativeCandidates(limit int) ([]Video, error) {
+	ctx := context.Background()
+	notified := db.Service.NewSelect().
+		Model((*Notification)(nil)).
+		Column("video_id").
+		Where("kind = ?", NotificationKindSongDiscord).
+		Where("status = ?", NotificationStatusSent)
+	labeled := db.Service.NewSelect().Model((*SongLabel)(nil)).Column("video_id")
+
+	// 公開前の歌動画は告知を送信していないため、公開済みの動画から選ぶ
+	var videos []Video
+	err := db.Service.NewSelect().
+		Model(&videos).
+		Where("id NOT IN (?)", notified).
+		Where("id NOT IN (?)", labeled).
+		Where("COALESCE(scheduled_start_time, created_at) < ?", time.Now().UTC()).
+		Where("title != ''").
+		OrderExpr("random()").
+		Limit(limit).
+		Scan(ctx)
+	if err != nil {
+		slog.Error(err.Error())
+		return nil, err
+	}
+	return videos, nil
+}
+
+func (db *DB) SavePanel(p Panel) error {
+	ctx := context.Background()
+	return retry.Do(
+		func() error {
+			_, err := db.Service.NewInsert().Model(&p).Ignore().Exec(ctx)
+			return err
+		},
+		retry.Attempts(3),
+		retry.Delay(1*time.Second),
+	)
+}
+
+func (db *DB) GetPanels() ([]Panel, error) {
+	var panels []Panel
+	ctx := context.Background()
+	err := db.Service.NewSelect().Model(&panels).Scan(ctx)
+	if err != nil {
+		slog.Error(err.Error())
+		return nil, err
+	}
+	return panels, nil
+}
+
+func (db *DB) DeletePanel(messageID string) error {
+	ctx := context.Background()
+	_, err := db.Service.NewDelete().Model((*Panel)(nil)).Where("message_id = ?", messageID).Exec(ctx)
+	if err != nil {
+		slog.Error(err.Error(),
+			slog.String("message_id", messageID),
+		)
+		return err
+	}
+	return nil
+}
+
+// 通知タスクを記録する　同じ動画、同じ通知時刻のタスクは実行時刻を上書きする
+func (db *DB) SaveReminder(r Reminder) error {
+	ctx := context.Background()
+	r.UpdatedAt = time.Now()
+	return retry.Do(
+		func() error {
+			_, err := db.Service.NewInsert().Model(&r).
+				On("CONFLICT (video_id, queue_id, minutes_ago) DO UPDATE").
+				Set("url = EXCLUDED.url").
+				Set("schedule_time = EXCLUDED.schedule_time").
+				Set("status = EXCLUDED.status").
+				Set("updated_at = EXCLUDED.updated_at").
+				Exec(ctx)
+			return err
+		},
+		retry.Attempts(3),
+		retry.Delay(1*time.Second),
+	)
+}
+
+// 指定したキューに登録されている動画の通知タスクをキャンセル済みにする
+func (db *DB) CancelReminders(queueID string, vid string) error {
+	ctx := context.Background()
+	return retry.Do(
+		func() error {
+			_, err := db.Service.NewUpdate().
+				Model((*Reminder)(nil)).
+				Set("status = ?", ReminderStatusCanceled).
+				Set("updated_at = ?", time.Now()).
+				Where("queue_id = ?", queueID).
+				Where("video_id = ?", vid).
+				Where("status IN (?)", bun.In([]string{ReminderStatusScheduled, ReminderStatusDeferred})).
+				Exec(ctx)
+			return err
+		},
+		retry.Attempts(3),
+		retry.Delay(1*time.Second),
+	)
+}
+
+// 動画の通知タスクの記録を通知時刻順に取得
+func (db *DB) GetReminders(vid string) ([]Reminder, error) {
+	var reminders []Reminder
+	ctx := context.Background()
+	err := db.Service.NewSelect().
+		Model(&reminders).
+		Where("video_id = ?", vid).
+		Order("schedule_time ASC").
+		Scan(ctx)
+	if err != nil {
+		slog.Error(err.Error(),
+			slog.String("video_id", vid),
+		)
+		return nil, err
+	}
+	return reminders, nil
+}
+
+// 指定したキューの未実行の通知タスクを、保留中のタスクも含めて通知時刻順に取得
+func (db *DB) GetUpcomingReminders(queueID string) ([]Reminder, error) {
+	var reminders []Reminder
+	ctx := context.Background()
+	err := db.Service.NewSelect().
+		Model(&reminders).
+		Where("queue_id = ?", queueID).
+		Where("status IN (?)", bun.In([]string{ReminderStatusScheduled, ReminderStatusDeferred})).
+		Where("schedule_time >= ?", time.Now().UTC()).
+		Order("schedule_time ASC").
+		Scan(ctx)
+	if err != nil {
+		slog.Error(err.Error())
+		return nil, err
+	}
+	return reminders, nil
+}
+
+func (db *DB) SaveAuditLog(l AuditLog) error {
+	ctx := context.Background()
+	return retry.Do(
+		func() error {
+			_, err := db.Service.NewInsert().Model(&l).Exec(ctx)
+			return err
+		},
+		retry.Attempts(3),
+		retry.Delay(1*time.Second),
+	)
+}
+
+// 登録したタスクの名前を保存する　同じ動画、同じ通知時刻のタスクは上書きする
+func (db *DB) SaveCloudTask(t CloudTask) error {
+	ctx := context.Background()
+	t.UpdatedAt = time.Now()
+	return retry.Do(
+		func() error {
+			_, err := db.Service.NewInsert().Model(&t).
+				On("CONFLICT (video_id, queue_id, minutes_ago) DO UPDATE").
+				Set("name = EXCLUDED.name").
+				Set("schedule_time = EXCLUDED.schedule_time").
+				Set("updated_at = EXCLUDED.updated_at").
+				Exec(ctx)
+			return err
+		},
+		retry.Attempts(3),
+		retry.Delay(1*time.Second),
+	)
+}
+
+// 指定したキューに登録した動画のタスクを取得
+func (db *DB) GetCloudTasks(queueID string, vid string) ([]CloudTask, error) {
+	var tasks []CloudTask
+	ctx := context.Background()
+	err := db.Service.NewSelect().
+		Model(&tasks).
+		Where("queue_id = ?", queueID).
+		Where("video_id = ?", vid).
+		Scan(ctx)
+	if err != nil {
+		slog.Error(err.Error(),
+			slog.String("video_id", vid),
+		)
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// 指定したキューに登録したタスクの名前を全て取得
+func (db *DB) GetCloudTaskNames(queueID string) ([]string, error) {
+	var names []string
+	ctx := context.Background()
+	err := db.Service.NewSelect().
+		Model((*CloudTask)(nil)).
+		Column("name").
+		Where("queue_id = ?", queueID).
+		Scan(ctx, &names)
+	if err != nil {
+		slog.Error(err.Error())
+		return nil, err
+	}
+	return names, nil
+}
+
+func (db *DB) DeleteCloudTask(t CloudTask) error {
+	ctx := context.Background()
+	return retry.Do(
+		func() error {
+			_, err := db.Service.NewDelete().
+				Model((*CloudTask)(nil)).
+				Where("queue_id = ?", t.QueueID).
+				Where("video_id = ?", t.VideoID).
+				Where("minutes_ago = ?", t.MinutesAgo).
+				Exec(ctx)
+			return err
+		},
+		retry.Attempts(3),
+		retry.Delay(1*time.Second),
+	)
+}
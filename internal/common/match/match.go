@@ -0,0 +1,500 @@
+package match
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/aopontann/niji-tuu/internal/common/normalize"
+	yt "google.golang.org/api/youtube/v3"
+)
+
+// キーワードの条件式
+//
+//	マイクラ                       タイトルに「マイクラ」を含む（まいくら、ﾏｲｸﾗ なども一致する）
+//	Minecraft AND コラボ           両方を含む（AND は省略可能）
+//	APEX OR VALORANT               どちらかを含む（| でも可）
+//	Minecraft NOT 切り抜き         「切り抜き」を含まない（-切り抜き でも可）
+//	"Original Song"                空白を含む語句
+//	title:歌枠 channel:葛葉 desc:MV 検索する項目を指定（tag: でタグも検索できる）
+//	(APEX OR VALORANT) 大会        括弧でまとめる
+type Expr interface {
+	Match(doc *Document) bool
+	String() string
+}
+
+// 条件式で検索する動画の情報
+type Document struct {
+	Title       string
+	Channel     string
+	Description string
+	Tags        []string
+	// 項目を指定していない語句を検索する範囲　空の場合はタイトルのみ検索する
+	Scopes []string
+}
+
+// 検索できる項目
+const (
+	FieldTitle       = "title"
+	FieldChannel     = "channel"
+	FieldDescription = "desc"
+	FieldTags        = "tag"
+)
+
+var fields = []string{FieldTitle, FieldChannel, FieldDescription, FieldTags}
+
+// キーワードごとに設定できる検索範囲
+const (
+	ScopeTitle       = "title"
+	ScopeDescription = "description"
+	ScopeTags        = "tags"
+	ScopeChannel     = "channel"
+)
+
+var scopeFields = map[string]string{
+	ScopeTitle:       FieldTitle,
+	ScopeDescription: FieldDescription,
+	ScopeTags:        FieldTags,
+	ScopeChannel:     FieldChannel,
+}
+
+// 動画情報から Document を作成する
+// チャンネルは vtubers テーブルに登録されている名前、YouTube のチャンネル名、チャンネルIDで検索できる
+func NewDocument(v yt.Video, channelName string, scopes []string) *Document {
+	doc := &Document{Scopes: scopes}
+	if v.Snippet == nil {
+		return doc
+	}
+	doc.Title = v.Snippet.Title
+	doc.Channel = strings.Join([]string{channelName, v.Snippet.ChannelTitle, v.Snippet.ChannelId}, " ")
+	doc.Description = v.Snippet.Description
+	doc.Tags = v.Snippet.Tags
+	return doc
+}
+
+// 検索範囲が正しいか検証する
+func ValidateScopes(scopes []string) error {
+	for _, s := range scopes {
+		if _, ok := scopeFields[s]; !ok {
+			return fmt.Errorf("検索範囲 %q は指定できません title, description, tags, channel のいずれかを指定してください", s)
+		}
+	}
+	return nil
+}
+
+// 検索する項目の値を返す　タグは1つずつ検索する
+func (d *Document) field(name string) []string {
+	switch name {
+	case FieldChannel:
+		return []string{d.Channel}
+	case FieldDescription:
+		return []string{d.Description}
+	case FieldTags:
+		return d.Tags
+	}
+	return []string{d.Title}
+}
+
+// 項目を指定していない語句を検索する項目
+func (d *Document) defaultFields() []string {
+	if len(d.Scopes) == 0 {
+		return []string{FieldTitle}
+	}
+	var fs []string
+	for _, s := range d.Scopes {
+		if f, ok := scopeFields[s]; ok {
+			fs = append(fs, f)
+		}
+	}
+	return fs
+}
+
+type termExpr struct {
+	field string
+	word  string
+}
+
+func (e *termExpr) Match(doc *Document) bool {
+	fs := []string{e.field}
+	if e.field == "" {
+		fs = doc.defaultFields()
+	}
+	for _, f := range fs {
+		for _, value := range doc.field(f) {
+			if strings.Contains(normalize.String(value), e.word) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (e *termExpr) String() string {
+	word := e.word
+	if strings.ContainsAny(word, " \t()\"|&") {
+		word = `"` + word + `"`
+	}
+	if e.field == "" {
+		return word
+	}
+	return e.field + ":" + word
+}
+
+type notExpr struct {
+	expr Expr
+}
+
+func (e *notExpr) Match(doc *Document) bool {
+	return !e.expr.Match(doc)
+}
+
+func (e *notExpr) String() string {
+	return "NOT " + e.expr.String()
+}
+
+type andExpr struct {
+	exprs []Expr
+}
+
+func (e *andExpr) Match(doc *Document) bool {
+	for _, expr := range e.exprs {
+		if !expr.Match(doc) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *andExpr) String() string {
+	return join(e.exprs, " AND ")
+}
+
+type orExpr struct {
+	exprs []Expr
+}
+
+func (e *orExpr) Match(doc *Document) bool {
+	for _, expr := range e.exprs {
+		if expr.Match(doc) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *orExpr) String() string {
+	return join(e.exprs, " OR ")
+}
+
+func join(exprs []Expr, sep string) string {
+	var s []string
+	for _, e := range exprs {
+		s = append(s, e.String())
+	}
+	return "(" + strings.Join(s, sep) + ")"
+}
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenPhrase
+	tokenLParen
+	tokenRParen
+	tokenAnd
+	tokenOr
+	tokenNot
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	// 項目を指定している場合の項目名
+	field string
+	pos   int
+}
+
+// 条件式を解析する
+func Parse(s string) (Expr, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("条件式が空です")
+	}
+
+	p := &parser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("%d文字目の %q が不正です", p.tokens[p.pos].pos+1, p.tokens[p.pos].value)
+	}
+	return expr, nil
+}
+
+// 正規化した後の語句の最小の文字数
+// 1文字の語句はほとんどの動画に一致してしまうため登録できない
+const minWordLength = 2
+
+// 条件式が正しいか検証する
+func Validate(s string) error {
+	if _, err := Parse(s); err != nil {
+		return err
+	}
+	tokens, err := tokenize(s)
+	if err != nil {
+		return err
+	}
+	for _, t := range tokens {
+		if t.kind != tokenWord && t.kind != tokenPhrase {
+			continue
+		}
+		if utf8.RuneCountInString(normalize.String(t.value)) < minWordLength {
+			return fmt.Errorf("%d文字目の %q は記号などを除くと%d文字未満のため登録できません", t.pos+1, t.value, minWordLength)
+		}
+	}
+	return nil
+}
+
+// 条件式で語句の前に指定している項目を返す 例 "desc:MV 歌枠" は [desc]
+func Fields(s string) ([]string, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+	var fs []string
+	for _, t := range tokens {
+		if t.field != "" && !slices.Contains(fs, t.field) {
+			fs = append(fs, t.field)
+		}
+	}
+	return fs, nil
+}
+
+// 条件式を導入する前の語句を、同じ意味の条件式に変換する
+// 以前は語句を | で連結した正規表現で一致チェックしていたため、| で区切った語句をそれぞれ1つの語句として扱う
+// 空白や演算子を含む語句は " で囲む
+// 正規表現の記号を含むなど、同じ意味に変換できない語句はエラーを返す
+func FromLegacy(term string) (string, error) {
+	if strings.ContainsAny(term, `.*+?()[]{}^$\`) {
+		return "", fmt.Errorf("正規表現の記号を含む語句は変換できません %q", term)
+	}
+
+	var exprs []string
+	for _, word := range strings.Split(term, "|") {
+		if strings.TrimSpace(word) == "" {
+			return "", fmt.Errorf("空の語句は変換できません %q", term)
+		}
+		if tokens, err := tokenize(word); err == nil && len(tokens) == 1 && tokens[0].kind == tokenWord && tokens[0].field == "" && tokens[0].value == word {
+			exprs = append(exprs, word)
+			continue
+		}
+		if strings.Contains(word, `"`) {
+			return "", fmt.Errorf("\" を含む語句は変換できません %q", term)
+		}
+		exprs = append(exprs, `"`+word+`"`)
+	}
+	return strings.Join(exprs, " OR "), nil
+}
+
+// 条件式のいずれかに一致するか
+// 解析できない条件式は一致しないものとして扱い、エラーを返す
+func MatchAny(exprs []string, doc *Document) (bool, error) {
+	var errs []string
+	for _, s := range exprs {
+		expr, err := Parse(s)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", s, err.Error()))
+			continue
+		}
+		if expr.Match(doc) {
+			return true, nil
+		}
+	}
+	if len(errs) != 0 {
+		return false, fmt.Errorf("不正な条件式があります %s", strings.Join(errs, ", "))
+	}
+	return false, nil
+}
+
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenLParen, value: "(", pos: i})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenRParen, value: ")", pos: i})
+			i++
+		case r == '|':
+			tokens = append(tokens, token{kind: tokenOr, value: "|", pos: i})
+			i++
+		case r == '&':
+			tokens = append(tokens, token{kind: tokenAnd, value: "&", pos: i})
+			i++
+		case r == '-' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]):
+			tokens = append(tokens, token{kind: tokenNot, value: "-", pos: i})
+			i++
+		default:
+			start := i
+			field := ""
+
+			// 項目の指定 例 title:
+			for _, f := range fields {
+				prefix := []rune(f + ":")
+				if i+len(prefix) <= len(runes) && strings.EqualFold(string(runes[i:i+len(prefix)]), string(prefix)) {
+					field = f
+					i += len(prefix)
+					break
+				}
+			}
+
+			if i < len(runes) && runes[i] == '"' {
+				end := i + 1
+				for end < len(runes) && runes[end] != '"' {
+					end++
+				}
+				if end >= len(runes) {
+					return nil, fmt.Errorf("%d文字目の \" が閉じられていません", i+1)
+				}
+				phrase := string(runes[i+1 : end])
+				if strings.TrimSpace(phrase) == "" {
+					return nil, fmt.Errorf("%d文字目の語句が空です", i+1)
+				}
+				tokens = append(tokens, token{kind: tokenPhrase, value: phrase, field: field, pos: start})
+				i = end + 1
+				continue
+			}
+
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && !strings.ContainsRune(`()"|&`, runes[end]) {
+				end++
+			}
+			word := string(runes[i:end])
+			if word == "" {
+				return nil, fmt.Errorf("%d文字目の %s: の後に語句がありません", start+1, field)
+			}
+			i = end
+
+			if field == "" {
+				switch word {
+				case "AND":
+					tokens = append(tokens, token{kind: tokenAnd, value: word, pos: start})
+					continue
+				case "OR":
+					tokens = append(tokens, token{kind: tokenOr, value: word, pos: start})
+					continue
+				case "NOT":
+					tokens = append(tokens, token{kind: tokenNot, value: word, pos: start})
+					continue
+				}
+			}
+			tokens = append(tokens, token{kind: tokenWord, value: word, field: field, pos: start})
+		}
+	}
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() *token {
+	if p.pos >= len(p.tokens) {
+		return nil
+	}
+	return &p.tokens[p.pos]
+}
+
+// or := and (OR and)*
+func (p *parser) parseOr() (Expr, error) {
+	expr, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	exprs := []Expr{expr}
+	for t := p.peek(); t != nil && t.kind == tokenOr; t = p.peek() {
+		p.pos++
+		expr, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+	}
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return &orExpr{exprs}, nil
+}
+
+// and := unary (AND? unary)*
+func (p *parser) parseAnd() (Expr, error) {
+	expr, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	exprs := []Expr{expr}
+	for t := p.peek(); t != nil && t.kind != tokenOr && t.kind != tokenRParen; t = p.peek() {
+		if t.kind == tokenAnd {
+			p.pos++
+		}
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+	}
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return &andExpr{exprs}, nil
+}
+
+// unary := NOT unary | "(" or ")" | term
+func (p *parser) parseUnary() (Expr, error) {
+	t := p.peek()
+	if t == nil {
+		return nil, fmt.Errorf("条件式が途中で終わっています")
+	}
+
+	switch t.kind {
+	case tokenNot:
+		p.pos++
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notExpr{expr}, nil
+	case tokenLParen:
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if c := p.peek(); c == nil || c.kind != tokenRParen {
+			return nil, fmt.Errorf("%d文字目の ( が閉じられていません", t.pos+1)
+		}
+		p.pos++
+		return expr, nil
+	case tokenWord, tokenPhrase:
+		p.pos++
+		// タイトルと同じく正規化してから一致チェックする
+		word := normalize.String(t.value)
+		if word == "" {
+			return nil, fmt.Errorf("%d文字目の %q は記号のみのため検索できません", t.pos+1, t.value)
+		}
+		return &termExpr{field: t.field, word: word}, nil
+	}
+	return nil, fmt.Errorf("%d文字目の %q が不正です", t.pos+1, t.value)
+}
@@ -0,0 +1,170 @@
+package match
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	doc := &Document{
+		Title:       "【Minecraft】葛葉とコラボ建築！ #にじさんじ",
+		Channel:     "Kuzuha Channel",
+		Description: "MV公開中 (C++)",
+		Tags:        []string{"Minecraft", "建築"},
+	}
+
+	cases := []struct {
+		expr string
+		want bool
+	}{
+		{"minecraft", true},
+		{"マイクラ", false},
+		{"ｍｉｎｅｃｒａｆｔ", true},
+		{"こらぼ", true},
+		{"Minecraft AND コラボ", true},
+		{"Minecraft コラボ", true},
+		{"Minecraft & 切り抜き", false},
+		{"APEX OR Minecraft", true},
+		{"APEX | VALORANT", false},
+		{"Minecraft NOT 切り抜き", true},
+		{"Minecraft -コラボ", false},
+		{`"コラボ建築！ #にじ"`, true},
+		{"channel:kuzuha", true},
+		{"title:kuzuha", false},
+		{"desc:MV", true},
+		{`desc:"(C++)"`, true},
+		{"(APEX OR Minecraft) AND NOT (切り抜き OR 雑談)", true},
+		{"NOT NOT minecraft", true},
+		{"tag:建築", true},
+		{"tag:雑談", false},
+		{`tag:"Minecraft 建築"`, false},
+	}
+	for _, c := range cases {
+		expr, err := Parse(c.expr)
+		if err != nil {
+			t.Errorf("Parse(%q) returned error: %v", c.expr, err)
+			continue
+		}
+		if got := expr.Match(doc); got != c.want {
+			t.Errorf("Parse(%q).Match() = %v, want %v (%s)", c.expr, got, c.want, expr)
+		}
+	}
+}
+
+func TestScopes(t *testing.T) {
+	doc := &Document{
+		Title:       "【APEX】ランクマッチ",
+		Channel:     "葛葉 Kuzuha Channel UCSFCh5NL4qXrAy9u-u2lX3g",
+		Description: "大会に向けて練習",
+		Tags:        []string{"Apex Legends", "にじさんじ"},
+	}
+
+	cases := []struct {
+		scopes []string
+		expr   string
+		want   bool
+	}{
+		{nil, "大会", false},
+		{[]string{ScopeTitle}, "大会", false},
+		{[]string{ScopeDescription}, "大会", true},
+		{[]string{ScopeTags}, "apex legends", true},
+		{[]string{ScopeTitle, ScopeTags}, "にじさんじ", true},
+		{[]string{ScopeChannel}, "UCSFCh5NL4qXrAy9u-u2lX3g", true},
+		// 項目を指定した語句は検索範囲に関係なく検索する
+		{[]string{ScopeDescription}, "title:ランクマッチ", true},
+	}
+	for _, c := range cases {
+		doc.Scopes = c.scopes
+		expr, err := Parse(c.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := expr.Match(doc); got != c.want {
+			t.Errorf("scopes %v: Parse(%q).Match() = %v, want %v", c.scopes, c.expr, got, c.want)
+		}
+	}
+
+	if err := ValidateScopes([]string{ScopeTitle, "comments"}); err == nil {
+		t.Error("ValidateScopes expected error")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	invalid := []string{
+		"",
+		"(APEX",
+		"APEX)",
+		`"APEX`,
+		"APEX OR",
+		"NOT",
+		"title:",
+		`""`,
+		"【】",
+		"歌",
+		"C++",
+		`"(a)"`,
+	}
+	for _, s := range invalid {
+		if err := Validate(s); err == nil {
+			t.Errorf("Validate(%q) expected error", s)
+		}
+	}
+}
+
+func TestMatchAny(t *testing.T) {
+	doc := &Document{Title: "APEX ランクマッチ"}
+
+	ok, err := MatchAny([]string{"VALORANT", "apex"}, doc)
+	if err != nil || !ok {
+		t.Errorf("expected match, got %v %v", ok, err)
+	}
+
+	ok, err = MatchAny([]string{"(VALORANT", "スト6"}, doc)
+	if err == nil || ok {
+		t.Errorf("expected error and no match, got %v %v", ok, err)
+	}
+}
+
+func TestFromLegacy(t *testing.T) {
+	cases := []struct {
+		term string
+		want string
+	}{
+		{"マイクラ", "マイクラ"},
+		{"APEX|VALORANT", "APEX OR VALORANT"},
+		{"Original Song", `"Original Song"`},
+		{"-切り抜き", `"-切り抜き"`},
+		{"OR", `"OR"`},
+		{"title:歌枠", `"title:歌枠"`},
+		{"R&B| 歌枠", `"R&B" OR " 歌枠"`},
+	}
+	for _, c := range cases {
+		got, err := FromLegacy(c.term)
+		if err != nil {
+			t.Errorf("FromLegacy(%q) error: %v", c.term, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("FromLegacy(%q) = %q, want %q", c.term, got, c.want)
+		}
+		if err := Validate(got); err != nil {
+			t.Errorf("FromLegacy(%q) = %q is invalid: %v", c.term, got, err)
+		}
+	}
+
+	for _, term := range []string{"apex.*大会", "(歌枠)", "APEX|", `"歌"`} {
+		if _, err := FromLegacy(term); err == nil {
+			t.Errorf("FromLegacy(%q) expected error", term)
+		}
+	}
+}
+
+func TestFields(t *testing.T) {
+	fs, err := Fields(`desc:MV 歌枠 OR tag:"Original Song" desc:歌`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fs) != 2 || fs[0] != FieldDescription || fs[1] != FieldTags {
+		t.Errorf("Fields() = %v", fs)
+	}
+	if fs, _ := Fields("歌枠 -切り抜き"); len(fs) != 0 {
+		t.Errorf("Fields() = %v", fs)
+	}
+}
@@ -0,0 +1,44 @@
+package normalize
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// タイトルやキーワードを比較するために表記ゆれをなくした文字列を返す
+//
+//   - NFKC正規化（全角英数字を半角に、半角カナを全角に）
+//   - 小文字に統一
+//   - カタカナをひらがなに統一
+//   - 長音記号を削除
+//   - 記号を空白に置き換える（前後の語句がつながって一致しないように）
+//   - 連続する空白を1つにまとめる
+func String(s string) string {
+	s = norm.NFKC.String(s)
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		switch {
+		// ー（長音記号）、〜（波ダッシュ）は表記ゆれが多いため削除
+		case r == 'ー' || r == '〜' || r == '~':
+			continue
+		// ァ〜ヶ をひらがなに変換
+		case r >= 'ァ' && r <= 'ヶ':
+			b.WriteRune(r - 'ァ' + 'ぁ')
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			b.WriteRune(' ')
+		default:
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// 正規化した s に、正規化した substr が含まれているか
+func Contains(s string, substr string) bool {
+	return strings.Contains(String(s), String(substr))
+}
@@ -0,0 +1,52 @@
+package normalize
+
+import "testing"
+
+func TestString(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"マイクラ", "まいくら"},
+		{"まいくら", "まいくら"},
+		{"ﾏｲｸﾗ", "まいくら"},
+		{"ＭＶ", "mv"},
+		{"【ＭＶ】シャルル／葛葉", "mv しゃるる 葛葉"},
+		{"【歌ってみた】ヴィラン / 葛葉 cover", "歌ってみた ゔぃらん 葛葉 cover"},
+		{"【#にじさんじ甲子園】ﾊﾟﾜﾌﾟﾛ実況！", "にじさんじ甲子園 ぱわぷろ実況"},
+		{"ＡＰＥＸ　ランクマッチ〜ソロ〜", "apex らんくまっちそろ"},
+		{"スーパーマリオ", "すぱまりお"},
+		{"すーぱーまりお", "すぱまりお"},
+		{"Original Song『 夢 』", "original song 夢"},
+		{"  複数   の  空白  ", "複数 の 空白"},
+		{"歌枠/雑談", "歌枠 雑談"},
+	}
+	for _, c := range cases {
+		if got := String(c.in); got != c.want {
+			t.Errorf("String(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestContains(t *testing.T) {
+	cases := []struct {
+		title string
+		word  string
+		want  bool
+	}{
+		{"【Minecraft】ﾏｲｸﾗでお城を建てる", "マイクラ", true},
+		{"【まいくら】新ワールド", "マイクラ", true},
+		{"【ＭＶ】オリジナル曲「星」", "MV", true},
+		{"【歌ってみた】KING／Kanaria【にじさんじ】", "歌ってみた", true},
+		{"【スト６】ランクマ", "スト6", true},
+		{"【切り抜き】面白シーン集", "切り抜き", true},
+		{"雑談配信", "マイクラ", false},
+		// 記号の前後の語句はつながらない
+		{"【歌枠】/雑談", "歌枠雑談", false},
+	}
+	for _, c := range cases {
+		if got := Contains(c.title, c.word); got != c.want {
+			t.Errorf("Contains(%q, %q) = %v, want %v", c.title, c.word, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package notifier
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/aopontann/niji-tuu/internal/common/db"
+	"github.com/aopontann/niji-tuu/internal/common/fcm"
+	"github.com/bwmarrin/discordgo"
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/go-retryablehttp"
+	yt "google.golang.org/api/youtube/v3"
+)
+
+// 通知先の種類
+const (
+	TargetTypeDiscordWebhook = "discord-webhook"
+	TargetTypeWebhook        = "webhook"
+	TargetTypeSlack          = "slack"
+)
+
+// 通知先を設定する単位
+const (
+	ScopeKeyword = "keyword"
+	ScopeFeature = "feature"
+)
+
+// 通知先を設定できる機能
+const (
+	FeatureSong      = "song"
+	FeatureMaybeSong = "maybe-song"
+)
+
+// 通知する動画のイベント
+type VideoEvent struct {
+	// 通知の種類 例 keyword, song-discord, maybe-song
+	Kind string
+	// 通知の見出し 例 5分後に公開
+	Message string
+	// キーワードに一致した場合のキーワード名
+	Keyword string
+	// メンションするDiscordのロールID
+	RoleID string
+	// vtubers テーブルに登録されている名前
+	ChannelName string
+	Video       yt.Video
+}
+
+// 動画のイベントを通知する
+// 送信したメッセージのIDを返す　IDがない通知先の場合は空文字を返す
+type Notifier interface {
+	Notify(event *VideoEvent) (string, error)
+}
+
+var (
+	_ Notifier = (*FCMNotifier)(nil)
+	_ Notifier = (*DiscordBotNotifier)(nil)
+	_ Notifier = (*DiscordWebhookNotifier)(nil)
+	_ Notifier = (*WebhookNotifier)(nil)
+)
+
+// 指定したトークン宛てにFCMでプッシュ通知を送信する
+type FCMNotifier struct {
+	FCM    *fcm.FCM
+	Tokens []string
+}
+
+func (n *FCMNotifier) Notify(event *VideoEvent) (string, error) {
+	v := event.Video
+	thumbnail := ""
+	if v.Snippet.Thumbnails != nil && v.Snippet.Thumbnails.High != nil {
+		thumbnail = v.Snippet.Thumbnails.High.Url
+	}
+	return "", n.FCM.Notification(event.Message, n.Tokens, &fcm.NotificationVideo{
+		ID:        v.Id,
+		Title:     v.Snippet.Title,
+		Thumbnail: thumbnail,
+	})
+}
+
+// Discord に送信するメッセージを作成する
+// 埋め込みなどの見た目は internal/discord/message で作成したものを渡す
+type DiscordMessageBuilder func(event *VideoEvent) *discordgo.MessageSend
+
+// Discord Bot から指定したチャンネルにメッセージを送信する
+type DiscordBotNotifier struct {
+	Session   *discordgo.Session
+	ChannelID string
+	Build     DiscordMessageBuilder
+}
+
+func (n *DiscordBotNotifier) Notify(event *VideoEvent) (string, error) {
+	msg, err := n.Session.ChannelMessageSendComplex(n.ChannelID, n.Build(event))
+	if err != nil {
+		return "", err
+	}
+	return msg.ID, nil
+}
+
+// DiscordのWebhookにメッセージを送信する
+type DiscordWebhookNotifier struct {
+	URL   string
+	Build DiscordMessageBuilder
+}
+
+func (n *DiscordWebhookNotifier) Notify(event *VideoEvent) (string, error) {
+	msg := n.Build(event)
+	params := discordgo.WebhookParams{
+		Content:         msg.Content,
+		Embeds:          msg.Embeds,
+		AllowedMentions: msg.AllowedMentions,
+	}
+	body, err := json.Marshal(params)
+	if err != nil {
+		return "", err
+	}
+	return "", post(n.URL, body, nil)
+}
+
+// 任意のURLに署名付きのJSONを送信する
+// 署名は X-Niji-Tuu-Signature ヘッダーに sha256=HMAC の形式で付与する
+type WebhookNotifier struct {
+	URL    string
+	Secret string
+}
+
+// WebhookNotifier が送信するJSON
+type WebhookPayload struct {
+	Kind               string `json:"kind"`
+	Keyword            string `json:"keyword,omitempty"`
+	VideoID            string `json:"video_id"`
+	Title              string `json:"title"`
+	URL                string `json:"url"`
+	ChannelID          string `json:"channel_id"`
+	ChannelName        string `json:"channel_name"`
+	LiveBroadcast      string `json:"live_broadcast_content"`
+	ScheduledStartTime string `json:"scheduled_start_time,omitempty"`
+	SentAt             string `json:"sent_at"`
+}
+
+func (n *WebhookNotifier) Notify(event *VideoEvent) (string, error) {
+	body, err := json.Marshal(NewWebhookPayload(event))
+	if err != nil {
+		return "", err
+	}
+	headers := map[string]string{
+		"X-Niji-Tuu-Signature": "sha256=" + Sign(body, n.Secret),
+	}
+	return "", post(n.URL, body, headers)
+}
+
+func NewWebhookPayload(event *VideoEvent) *WebhookPayload {
+	v := event.Video
+	channelName := event.ChannelName
+	if channelName == "" {
+		channelName = v.Snippet.ChannelTitle
+	}
+	payload := &WebhookPayload{
+		Kind:          event.Kind,
+		Keyword:       event.Keyword,
+		VideoID:       v.Id,
+		Title:         v.Snippet.Title,
+		URL:           "https://www.youtube.com/watch?v=" + v.Id,
+		ChannelID:     v.Snippet.ChannelId,
+		ChannelName:   channelName,
+		LiveBroadcast: v.Snippet.LiveBroadcastContent,
+		SentAt:        time.Now().UTC().Format(time.RFC3339),
+	}
+	if v.LiveStreamingDetails != nil {
+		payload.ScheduledStartTime = v.LiveStreamingDetails.ScheduledStartTime
+	}
+	return payload
+}
+
+// 本文のHMAC-SHA256を16進数で返す
+func Sign(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// DBに登録されている通知先から Notifier を作成する
+// Discord の Webhook には build で作成したメッセージを送信する
+func FromTargets(targets []db.NotifyTarget, build DiscordMessageBuilder) []Notifier {
+	var notifiers []Notifier
+	for _, t := range targets {
+		switch t.Type {
+		case TargetTypeDiscordWebhook:
+			notifiers = append(notifiers, &DiscordWebhookNotifier{URL: t.URL, Build: build})
+		case TargetTypeWebhook:
+			notifiers = append(notifiers, &WebhookNotifier{URL: t.URL, Secret: t.Secret})
+		case TargetTypeSlack:
+			notifiers = append(notifiers, &SlackNotifier{URL: t.URL})
+		default:
+			slog.Warn("未対応の通知先です",
+				slog.Int64("target_id", t.ID),
+				slog.String("type", t.Type),
+			)
+		}
+	}
+	return notifiers
+}
+
+// 全ての通知先に送信する　どれかの送信が失敗しても、他の通知先には影響が出ないように
+func NotifyAll(notifiers []Notifier, event *VideoEvent) error {
+	var meg multierror.Group
+	for _, n := range notifiers {
+		meg.Go(func() error {
+			_, err := n.Notify(event)
+			if err != nil {
+				slog.Error(err.Error(),
+					slog.String("video_id", event.Video.Id),
+				)
+			}
+			return err
+		})
+	}
+	merr := meg.Wait()
+	return merr.ErrorOrNil()
+}
+
+// JSONをPOSTする　失敗した場合はリトライする
+func post(url string, body []byte, headers map[string]string) error {
+	retryClient := retryablehttp.NewClient()
+	retryClient.RetryMax = 2
+	retryClient.RetryWaitMin = 1 * time.Second
+	retryClient.Logger = slog.Default()
+
+	req, err := retryablehttp.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := retryClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("failed to post %s: %d %s", url, resp.StatusCode, string(respBody))
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package notifier
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"google.golang.org/api/youtube/v3"
+)
+
+func TestWebhookNotifier(t *testing.T) {
+	var payload WebhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("X-Niji-Tuu-Signature") != "sha256="+Sign(body, "secret") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.Unmarshal(body, &payload)
+	}))
+	defer server.Close()
+
+	n := &WebhookNotifier{URL: server.URL, Secret: "secret"}
+	_, err := n.Notify(&VideoEvent{
+		Kind:    "keyword",
+		Keyword: "マイクラ",
+		Video: youtube.Video{
+			Id:                   "EgaXyUcsM48",
+			Snippet:              &youtube.VideoSnippet{Title: "【Minecraft】建築", ChannelId: "UC0g1AE0DOjBYnLhkgoRWN1w", ChannelTitle: "チャンネル"},
+			LiveStreamingDetails: &youtube.VideoLiveStreamingDetails{ScheduledStartTime: "2025-04-01T12:00:00Z"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if payload.VideoID != "EgaXyUcsM48" || payload.Keyword != "マイクラ" || payload.ChannelName != "チャンネル" {
+		t.Errorf("unexpected payload %+v", payload)
+	}
+	if payload.ScheduledStartTime != "2025-04-01T12:00:00Z" {
+		t.Errorf("unexpected scheduled start time %s", payload.ScheduledStartTime)
+	}
+}
+
+func TestNewSlackMessage(t *testing.T) {
+	msg := NewSlackMessage(&VideoEvent{
+		Keyword: "APEX",
+		Video: youtube.Video{
+			Id:                   "EgaXyUcsM48",
+			Snippet:              &youtube.VideoSnippet{Title: "<APEX>&ランク", ChannelTitle: "チャンネル"},
+			LiveStreamingDetails: &youtube.VideoLiveStreamingDetails{ScheduledStartTime: "2025-04-01T12:00:00Z"},
+		},
+	})
+
+	if len(msg.Blocks) != 2 {
+		t.Fatalf("expected 2 blocks, got %d", len(msg.Blocks))
+	}
+	if got := msg.Blocks[0].Text.Text; got != "*<https://www.youtube.com/watch?v=EgaXyUcsM48|&lt;APEX&gt;&amp;ランク>*" {
+		t.Errorf("unexpected title %q", got)
+	}
+	if got := msg.Blocks[0].Fields[1].Text; got != "*公開予定*\n<!date^1743508800^{date_short_pretty} {time}|2025/04/01 21:00>" {
+		t.Errorf("unexpected scheduled time %q", got)
+	}
+}
+
+func TestDiscordWebhookNotifier(t *testing.T) {
+	var params discordgo.WebhookParams
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&params)
+	}))
+	defer server.Close()
+
+	n := &DiscordWebhookNotifier{
+		URL: server.URL,
+		Build: func(event *VideoEvent) *discordgo.MessageSend {
+			return &discordgo.MessageSend{
+				Content: event.Message,
+				Embeds:  []*discordgo.MessageEmbed{{Title: event.Video.Snippet.Title}},
+			}
+		},
+	}
+	_, err := n.Notify(&VideoEvent{
+		Message: "5分後に公開",
+		Video:   youtube.Video{Id: "EgaXyUcsM48", Snippet: &youtube.VideoSnippet{Title: "【歌ってみた】"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Content != "5分後に公開" || len(params.Embeds) != 1 || params.Embeds[0].Title != "【歌ってみた】" {
+		t.Errorf("unexpected params %+v", params)
+	}
+}
+
+func TestSlackNotifierRetry(t *testing.T) {
+	slackRetryDelay = time.Millisecond
+	defer func() { slackRetryDelay = time.Second }()
+
+	cases := []struct {
+		status int
+		want   int
+	}{
+		{http.StatusOK, 1},
+		{http.StatusNotFound, 1},
+		{http.StatusTooManyRequests, 3},
+		{http.StatusInternalServerError, 3},
+	}
+	for _, c := range cases {
+		count := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			count++
+			w.WriteHeader(c.status)
+		}))
+
+		n := &SlackNotifier{URL: server.URL}
+		_, err := n.Notify(&VideoEvent{Video: youtube.Video{Id: "EgaXyUcsM48", Snippet: &youtube.VideoSnippet{Title: "タイトル"}}})
+		server.Close()
+		if (err == nil) != (c.status == http.StatusOK) {
+			t.Errorf("status %d: unexpected error %v", c.status, err)
+		}
+		if count != c.want {
+			t.Errorf("status %d: requested %d times, want %d", c.status, count, c.want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/avast/retry-go/v4"
+)
+
+// SlackのIncoming Webhookに Block Kit 形式のメッセージを送信する
+type SlackNotifier struct {
+	URL string
+}
+
+var _ Notifier = (*SlackNotifier)(nil)
+
+// Block Kit のブロック　使用するフィールドのみ定義
+type SlackBlock struct {
+	Type      string        `json:"type"`
+	Text      *SlackText    `json:"text,omitempty"`
+	Fields    []*SlackText  `json:"fields,omitempty"`
+	Accessory *SlackElement `json:"accessory,omitempty"`
+	Elements  []*SlackText  `json:"elements,omitempty"`
+}
+
+type SlackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type SlackElement struct {
+	Type     string `json:"type"`
+	ImageURL string `json:"image_url"`
+	AltText  string `json:"alt_text"`
+}
+
+type SlackMessage struct {
+	// 通知やブロックを表示できない環境で表示されるテキスト
+	Text   string        `json:"text"`
+	Blocks []*SlackBlock `json:"blocks"`
+}
+
+// Slack への送信に使うクライアント　応答がない場合に処理が止まらないようにタイムアウトを設定する
+var slackClient = &http.Client{Timeout: 10 * time.Second}
+
+// リトライするまでの時間　テストで短くできるように変数にする
+var slackRetryDelay = 1 * time.Second
+
+func (n *SlackNotifier) Notify(event *VideoEvent) (string, error) {
+	body, err := json.Marshal(NewSlackMessage(event))
+	if err != nil {
+		return "", err
+	}
+
+	// 3回までリトライ　1秒後にリトライ
+	// 429以外の4xxはリトライしても成功しないため、すぐに失敗させる
+	err = retry.Do(
+		func() error {
+			resp, err := slackClient.Post(n.URL, "application/json", bytes.NewReader(body))
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			respBody, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+			err = fmt.Errorf("failed to post slack webhook: %d %s", resp.StatusCode, string(respBody))
+			if resp.StatusCode/100 == 4 && resp.StatusCode != http.StatusTooManyRequests {
+				return retry.Unrecoverable(err)
+			}
+			return err
+		},
+		retry.Attempts(3),
+		retry.Delay(slackRetryDelay),
+		retry.LastErrorOnly(true),
+	)
+	return "", err
+}
+
+// サムネイル、タイトル、チャンネル名、公開予定時刻を表示するメッセージを作成する
+func NewSlackMessage(event *VideoEvent) *SlackMessage {
+	v := event.Video
+	url := "https://www.youtube.com/watch?v=" + v.Id
+	channelName := event.ChannelName
+	if channelName == "" {
+		channelName = v.Snippet.ChannelTitle
+	}
+
+	section := &SlackBlock{
+		Type: "section",
+		Text: &SlackText{Type: "mrkdwn", Text: fmt.Sprintf("*<%s|%s>*", url, escapeSlack(v.Snippet.Title))},
+		Fields: []*SlackText{
+			{Type: "mrkdwn", Text: "*チャンネル*\n" + escapeSlack(channelName)},
+		},
+	}
+	if v.LiveStreamingDetails != nil {
+		if t, err := time.Parse(time.RFC3339, v.LiveStreamingDetails.ScheduledStartTime); err == nil {
+			// 閲覧者のタイムゾーンで表示される　表示できない場合は日本時間で表示する
+			fallback := t.In(time.FixedZone("JST", 9*60*60)).Format("2006/01/02 15:04")
+			section.Fields = append(section.Fields, &SlackText{
+				Type: "mrkdwn",
+				Text: fmt.Sprintf("*公開予定*\n<!date^%d^{date_short_pretty} {time}|%s>", t.Unix(), fallback),
+			})
+		}
+	}
+	if v.Snippet.Thumbnails != nil && v.Snippet.Thumbnails.High != nil {
+		section.Accessory = &SlackElement{Type: "image", ImageURL: v.Snippet.Thumbnails.High.Url, AltText: v.Snippet.Title}
+	}
+
+	blocks := []*SlackBlock{section}
+	if event.Keyword != "" {
+		blocks = append(blocks, &SlackBlock{
+			Type:     "context",
+			Elements: []*SlackText{{Type: "mrkdwn", Text: "キーワード: " + escapeSlack(event.Keyword)}},
+		})
+	}
+
+	return &SlackMessage{
+		Text:   fmt.Sprintf("%s %s", v.Snippet.Title, url),
+		Blocks: blocks,
+	}
+}
+
+// Slackの mrkdwn で制御文字として扱われる文字をエスケープする
+func escapeSlack(s string) string {
+	var buf bytes.Buffer
+	for _, r := range s {
+		switch r {
+		case '&':
+			buf.WriteString("&amp;")
+		case '<':
+			buf.WriteString("&lt;")
+		case '>':
+			buf.WriteString("&gt;")
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	return buf.String()
+}
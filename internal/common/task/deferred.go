@@ -0,0 +1,84 @@
+package task
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/aopontann/niji-tuu/internal/common/db"
+	"google.golang.org/api/youtube/v3"
+)
+
+// Cloud Tasks に登録できない31日以降のタスクを、DBの pending_tasks テーブルに保留する
+// 保留したタスクは pending-task の定期実行で Cloud Tasks に登録し直す
+type DeferredTask struct {
+	Scheduler Scheduler
+	DB        *db.DB
+}
+
+func NewDeferredTask(s Scheduler, cdb *db.DB) *DeferredTask {
+	return &DeferredTask{s, cdb}
+}
+
+func (t *DeferredTask) Close() error {
+	return t.Scheduler.Close()
+}
+
+// タスクを作成する　実行時刻が31日以降の場合はDBに保留する
+func (t *DeferredTask) Create(info *TaskInfo) error {
+	return t.deferIfOutOfRange(info, t.Scheduler.Create(info))
+}
+
+// 登録済みのタスクと保留中のタスクを全て削除する
+func (t *DeferredTask) Delete(queueID string, vid string) error {
+	if err := t.DB.DeletePendingTasks(queueID, vid); err != nil {
+		return err
+	}
+	return t.Scheduler.Delete(queueID, vid)
+}
+
+// 登録済みのタスクを削除して、新しい実行時刻でタスクを作成する
+// 実行時刻が31日以降の場合はDBに保留する
+func (t *DeferredTask) Replace(info *TaskInfo) error {
+	err := t.Scheduler.Replace(info)
+	if err != nil {
+		return t.deferIfOutOfRange(info, err)
+	}
+
+	// 公開予定時刻が早まって登録できた場合は、保留中のタスクを削除する
+	return t.DB.DeletePendingTask(db.PendingTask{
+		VideoID:    info.Video.Id,
+		QueueID:    info.QueueID,
+		MinutesAgo: int64(info.MinutesAgo.Minutes()),
+	})
+}
+
+func (t *DeferredTask) deferIfOutOfRange(info *TaskInfo, err error) error {
+	if !errors.Is(err, ErrOutOfRange) {
+		return err
+	}
+
+	v := info.Video
+	slog.Info("DeferTask",
+		slog.String("video_id", v.Id),
+		slog.String("video_title", v.Snippet.Title),
+	)
+
+	return t.DB.SavePendingTask(db.PendingTask{
+		VideoID:    v.Id,
+		QueueID:    info.QueueID,
+		MinutesAgo: int64(info.MinutesAgo.Minutes()),
+		URL:        info.URL,
+		StartTime:  db.ScheduledStartTime(v),
+	})
+}
+
+// 保留中のタスクと再取得した動画情報から TaskInfo を作成する
+func PendingTaskInfo(pt db.PendingTask, v youtube.Video) *TaskInfo {
+	return &TaskInfo{
+		Video:      v,
+		QueueID:    pt.QueueID,
+		URL:        pt.URL,
+		MinutesAgo: time.Duration(pt.MinutesAgo) * time.Minute,
+	}
+}
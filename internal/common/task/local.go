@@ -0,0 +1,271 @@
+package task
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/aopontann/niji-tuu/internal/common/db"
+	"github.com/avast/retry-go/v4"
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/uptrace/bun"
+)
+
+const (
+	// 1回の実行で処理するタスク数
+	dispatchBatchSize = 50
+	// 失敗したタスクを諦めるまでの実行回数
+	maxDispatchAttempts = 5
+	// running のままこの時間を過ぎたタスクは、送信中にワーカーが終了したとみなして再送する
+	runningTimeout = 10 * time.Minute
+)
+
+// Cloud Tasks を使わずに、DBの scheduled_tasks テーブルにタスクを登録する
+// 登録したタスクは Run で起動したワーカーが実行時刻になったら送信する
+type LocalTask struct {
+	DB     *db.DB
+	Client *retryablehttp.Client
+}
+
+// DBは呼び出し元で閉じる
+func NewLocalTask(cdb *db.DB) *LocalTask {
+	retryClient := retryablehttp.NewClient()
+	retryClient.RetryMax = 2
+	retryClient.RetryWaitMin = 1 * time.Second
+	retryClient.Logger = slog.Default()
+
+	return &LocalTask{cdb, retryClient}
+}
+
+func (t *LocalTask) Close() error {
+	return nil
+}
+
+// タスク名を生成する　Cloud Tasks と同じく実行時刻を含める
+func (t *LocalTask) TaskName(info *TaskInfo, scheduleTime time.Time) string {
+	return fmt.Sprintf("%s/%s%d", info.QueueID, taskPrefix(info.Video.Id, info.MinutesAgo), scheduleTime.Unix())
+}
+
+// 動画開始時刻の 〇分前 に指定のURLにHTTPリクエストを送るタスクをDBに登録
+// 登録済みのタスクの場合は何もしない
+func (t *LocalTask) Create(info *TaskInfo) error {
+	ctx := context.Background()
+	v := info.Video
+	scheduleTime := scheduleTime(info).UTC()
+
+	task := &db.ScheduledTask{
+		Name:         t.TaskName(info, scheduleTime),
+		QueueID:      info.QueueID,
+		VideoID:      v.Id,
+		MinutesAgo:   int64(info.MinutesAgo.Minutes()),
+		URL:          fmt.Sprintf("%s?v=%s", info.URL, v.Id),
+		ScheduleTime: scheduleTime,
+		Status:       db.ScheduledTaskStatusPending,
+	}
+
+	slog.Info("CreateTask",
+		slog.String("video_id", v.Id),
+		slog.String("video_title", v.Snippet.Title),
+		slog.String("task_name", task.Name),
+	)
+
+	err := retry.Do(
+		func() error {
+			_, err := t.DB.Service.NewInsert().Model(task).Ignore().Exec(ctx)
+			return err
+		},
+		retry.Attempts(3),
+		retry.Delay(1*time.Second),
+	)
+	if err != nil {
+		slog.Error(err.Error(),
+			slog.String("video_id", v.Id),
+		)
+		return err
+	}
+	return nil
+}
+
+// 指定したキューに登録されている動画の未実行タスクを全て削除する
+func (t *LocalTask) Delete(queueID string, vid string) error {
+	ctx := context.Background()
+	_, err := t.DB.Service.NewDelete().
+		Model((*db.ScheduledTask)(nil)).
+		Where("queue_id = ?", queueID).
+		Where("video_id = ?", vid).
+		Where("status = ?", db.ScheduledTaskStatusPending).
+		Exec(ctx)
+	if err != nil {
+		slog.Error(err.Error(),
+			slog.String("video_id", vid),
+		)
+		return err
+	}
+	return nil
+}
+
+// 同じ動画、同じ通知時刻の未実行タスクを削除して、新しい実行時刻でタスクを作成する
+func (t *LocalTask) Replace(info *TaskInfo) error {
+	ctx := context.Background()
+	v := info.Video
+	name := t.TaskName(info, scheduleTime(info).UTC())
+
+	_, err := t.DB.Service.NewDelete().
+		Model((*db.ScheduledTask)(nil)).
+		Where("queue_id = ?", info.QueueID).
+		Where("video_id = ?", v.Id).
+		Where("minutes_ago = ?", int64(info.MinutesAgo.Minutes())).
+		Where("status = ?", db.ScheduledTaskStatusPending).
+		Where("name != ?", name).
+		Exec(ctx)
+	if err != nil {
+		slog.Error(err.Error(),
+			slog.String("video_id", v.Id),
+		)
+		return err
+	}
+
+	return t.Create(info)
+}
+
+// 指定した間隔で実行時刻を過ぎたタスクを送信する　ctx がキャンセルされるまで終了しない
+func (t *LocalTask) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := t.Dispatch(ctx); err != nil {
+			slog.Error(err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// 実行時刻を過ぎたタスクを送信し、処理したタスク数を返す
+// 複数のワーカーが同時に動いても同じタスクを送信しないように、取得したタスクを running にしてから送信する
+// 送信中に行ロックを持ち続けないように、取得と結果の記録は別々に行う
+func (t *LocalTask) Dispatch(ctx context.Context) (int, error) {
+	tasks, err := t.claim(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var merr *multierror.Error
+	for i := range tasks {
+		task := &tasks[i]
+		task.Attempts++
+		task.UpdatedAt = time.Now()
+
+		err := t.send(task.URL)
+		switch {
+		case err == nil:
+			task.Status = db.ScheduledTaskStatusDone
+			task.LastError = ""
+		case task.Attempts >= maxDispatchAttempts:
+			task.Status = db.ScheduledTaskStatusFailed
+			task.LastError = err.Error()
+		default:
+			// 失敗した回数に応じて次の実行時刻を遅らせる
+			task.Status = db.ScheduledTaskStatusPending
+			task.ScheduleTime = time.Now().UTC().Add(time.Duration(task.Attempts*task.Attempts) * time.Minute)
+			task.LastError = err.Error()
+		}
+
+		if err != nil {
+			slog.Warn(err.Error(),
+				slog.String("task_name", task.Name),
+				slog.Int64("attempts", task.Attempts),
+			)
+		}
+
+		// 記録に失敗しても送信済みのタスクは戻さない　running のまま残り、runningTimeout 後に再送される
+		err = retry.Do(
+			func() error {
+				_, err := t.DB.Service.NewUpdate().
+					Model(task).
+					Column("status", "attempts", "schedule_time", "last_error", "updated_at").
+					WherePK().
+					Exec(ctx)
+				return err
+			},
+			retry.Attempts(3),
+			retry.Delay(1*time.Second),
+		)
+		if err != nil {
+			slog.Error(err.Error(),
+				slog.String("task_name", task.Name),
+			)
+			merr = multierror.Append(merr, fmt.Errorf("%s: %w", task.Name, err))
+		}
+	}
+
+	return len(tasks), merr.ErrorOrNil()
+}
+
+// 実行時刻を過ぎたタスクを running にして取得する
+// 送信中にワーカーが終了した場合に備えて、running のまま runningTimeout を過ぎたタスクも取得し直す
+func (t *LocalTask) claim(ctx context.Context) ([]db.ScheduledTask, error) {
+	var tasks []db.ScheduledTask
+	err := t.DB.Service.RunInTx(ctx, &sql.TxOptions{}, func(ctx context.Context, tx bun.Tx) error {
+		now := time.Now().UTC()
+		err := tx.NewSelect().
+			Model(&tasks).
+			WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+				return q.
+					WhereGroup(" OR ", func(q *bun.SelectQuery) *bun.SelectQuery {
+						return q.Where("status = ?", db.ScheduledTaskStatusPending).Where("schedule_time <= ?", now)
+					}).
+					WhereGroup(" OR ", func(q *bun.SelectQuery) *bun.SelectQuery {
+						return q.Where("status = ?", db.ScheduledTaskStatusRunning).Where("updated_at <= ?", now.Add(-runningTimeout))
+					})
+			}).
+			Order("schedule_time ASC").
+			Limit(dispatchBatchSize).
+			For("UPDATE SKIP LOCKED").
+			Scan(ctx)
+		if err != nil || len(tasks) == 0 {
+			return err
+		}
+
+		names := make([]string, 0, len(tasks))
+		for _, task := range tasks {
+			names = append(names, task.Name)
+		}
+		_, err = tx.NewUpdate().
+			Model((*db.ScheduledTask)(nil)).
+			Set("status = ?", db.ScheduledTaskStatusRunning).
+			Set("updated_at = ?", now).
+			Where("name IN (?)", bun.In(names)).
+			Exec(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// タスクのURLにPOSTリクエストを送信する
+func (t *LocalTask) send(url string) error {
+	resp, err := t.Client.Post(url, "application/json", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// keepAliveできずにコネクションが再利用されずに終了してしまうため、bodyを読みきる
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, url)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package task
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aopontann/niji-tuu/internal/common/db"
+	"google.golang.org/api/youtube/v3"
+)
+
+func TestLocalTaskDispatch(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.URL.Query().Get("v"))
+	}))
+	defer server.Close()
+
+	cdb, err := db.NewDB(os.Getenv("DSN"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cdb.Close()
+	ltask := NewLocalTask(cdb)
+
+	// 公開予定時刻を過ぎた動画のため、すぐに実行される
+	video := youtube.Video{
+		Id:                   "EgaXyUcsM48",
+		Snippet:              &youtube.VideoSnippet{Title: "test"},
+		LiveStreamingDetails: &youtube.VideoLiveStreamingDetails{ScheduledStartTime: time.Now().UTC().Add(-time.Hour).Format("2006-01-02T15:04:05Z")},
+	}
+	info := &TaskInfo{
+		Video:      video,
+		QueueID:    "test-queue",
+		URL:        server.URL,
+		MinutesAgo: 5 * time.Minute,
+	}
+
+	ctx := context.Background()
+	defer ltask.DB.Service.NewDelete().Model((*db.ScheduledTask)(nil)).Where("queue_id = ?", "test-queue").Exec(ctx)
+
+	if err := ltask.Replace(info); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := ltask.Dispatch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || len(received) != 1 || received[0] != video.Id {
+		t.Fatalf("expected 1 dispatched task, got %d %v", n, received)
+	}
+
+	// 実行済みのタスクは再度送信されない
+	n, err = ltask.Dispatch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Fatalf("expected no dispatched task, got %d", n)
+	}
+}
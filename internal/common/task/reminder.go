@@ -0,0 +1,74 @@
+package task
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/aopontann/niji-tuu/internal/common/db"
+)
+
+// 登録したタスクを reminders テーブルに記録する
+// /song list などで登録済みの通知を確認できるように、全てのタスクの登録、削除を記録する
+type RecordedTask struct {
+	Scheduler Scheduler
+	DB        *db.DB
+}
+
+func NewRecordedTask(s Scheduler, cdb *db.DB) *RecordedTask {
+	return &RecordedTask{s, cdb}
+}
+
+func (t *RecordedTask) Create(info *TaskInfo) error {
+	if err := t.Scheduler.Create(info); err != nil {
+		return err
+	}
+	return t.record(info)
+}
+
+func (t *RecordedTask) Delete(queueID string, vid string) error {
+	if err := t.Scheduler.Delete(queueID, vid); err != nil {
+		return err
+	}
+	return t.DB.CancelReminders(queueID, vid)
+}
+
+func (t *RecordedTask) Replace(info *TaskInfo) error {
+	if err := t.Scheduler.Replace(info); err != nil {
+		return err
+	}
+	return t.record(info)
+}
+
+func (t *RecordedTask) Close() error {
+	return t.Scheduler.Close()
+}
+
+// DeferredTask が保留したタスクは保留中として記録する
+func (t *RecordedTask) record(info *TaskInfo) error {
+	status := db.ReminderStatusScheduled
+	if _, ok := t.Scheduler.(*DeferredTask); ok && !InRange(info) {
+		status = db.ReminderStatusDeferred
+	}
+	err := t.DB.SaveReminder(NewReminder(info, status))
+	if err != nil {
+		// 記録が Cloud Tasks とずれないように、タスクを登録できていてもエラーにする
+		// 同じタスクを登録し直すと記録し直せる
+		slog.Error(err.Error(),
+			slog.String("video_id", info.Video.Id),
+		)
+		return fmt.Errorf("タスクは登録しましたが、通知の記録に失敗しました %w", err)
+	}
+	return nil
+}
+
+// タスクの情報から reminders テーブルに記録する内容を作成する
+func NewReminder(info *TaskInfo, status string) db.Reminder {
+	return db.Reminder{
+		VideoID:      info.Video.Id,
+		QueueID:      info.QueueID,
+		MinutesAgo:   int64(info.MinutesAgo.Minutes()),
+		URL:          info.URL,
+		ScheduleTime: scheduleTime(info).UTC(),
+		Status:       status,
+	}
+}
@@ -2,21 +2,53 @@ package task
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/url"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/aopontann/niji-tuu/internal/common/db"
+
 	cloudtasks "cloud.google.com/go/cloudtasks/apiv2"
 	taskspb "cloud.google.com/go/cloudtasks/apiv2/cloudtaskspb"
 	"github.com/avast/retry-go/v4"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/youtube/v3"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+// タスクの登録先を切り替えるためのインターフェース
+type Scheduler interface {
+	Create(info *TaskInfo) error
+	Delete(queueID string, vid string) error
+	Replace(info *TaskInfo) error
+	// Cloud Tasks のクライアントを閉じる　DBは呼び出し元で閉じる
+	Close() error
+}
+
+var (
+	_ Scheduler = (*Task)(nil)
+	_ Scheduler = (*LocalTask)(nil)
+	_ Scheduler = (*DeferredTask)(nil)
+	_ Scheduler = (*RecordedTask)(nil)
+)
+
+// Cloud Tasks に登録できる実行時刻の上限を超えている場合のエラー
+var ErrOutOfRange = errors.New("schedule time is too far in the future")
+
+// Cloud Tasks に登録できる実行時刻の上限（日数）
+const maxScheduleDays = 30
+
 type Task struct {
-	Client     *cloudtasks.Client
+	Client *cloudtasks.Client
+	// 登録したタスクの名前を記録するDB　CreateHTTPTask のみ使う場合は nil でよい
+	DB         *db.DB
 	projectID  string
 	locationID string
 }
@@ -28,7 +60,35 @@ type TaskInfo struct {
 	MinutesAgo time.Duration
 }
 
-func NewTask() (*Task, error) {
+// 環境変数 SCHEDULER が local の場合はDBにタスクを登録する
+// それ以外の場合は Cloud Tasks にタスクを登録し、上限を超えるタスクはDBに保留する
+// どちらの場合も登録したタスクを reminders テーブルに記録する
+// 使い終わったら Close を呼ぶこと
+func NewScheduler(cdb *db.DB) (Scheduler, error) {
+	return newScheduler(cdb, true)
+}
+
+// NewScheduler と同じ登録先にタスクを登録するが、上限を超えるタスクを保留せずに ErrOutOfRange を返す
+// 保留中のタスクを登録し直す場合に使う
+func NewUndeferredScheduler(cdb *db.DB) (Scheduler, error) {
+	return newScheduler(cdb, false)
+}
+
+func newScheduler(cdb *db.DB, deferred bool) (Scheduler, error) {
+	if os.Getenv("SCHEDULER") == "local" {
+		return NewRecordedTask(NewLocalTask(cdb), cdb), nil
+	}
+	ctask, err := NewTask(cdb)
+	if err != nil {
+		return nil, err
+	}
+	if deferred {
+		return NewRecordedTask(NewDeferredTask(ctask, cdb), cdb), nil
+	}
+	return NewRecordedTask(ctask, cdb), nil
+}
+
+func NewTask(cdb *db.DB) (*Task, error) {
 	ctx := context.Background()
 	client, err := cloudtasks.NewClient(ctx)
 	if err != nil {
@@ -36,37 +96,146 @@ func NewTask() (*Task, error) {
 	}
 
 	return &Task{
-		client,
-		os.Getenv("PROJECT_ID"),
-		os.Getenv("LOCATION_ID"),
+		Client:     client,
+		DB:         cdb,
+		projectID:  os.Getenv("PROJECT_ID"),
+		locationID: os.Getenv("LOCATION_ID"),
 	}, nil
 }
 
+func (t *Task) Close() error {
+	return t.Client.Close()
+}
+
+// タスクを登録するキューのパスを返す
+func (t *Task) queuePath(queueID string) string {
+	return fmt.Sprintf("projects/%s/locations/%s/queues/%s", t.projectID, t.locationID, queueID)
+}
+
+// キュー内で動画を識別するタスク名の接頭辞を返す
+// minutesAgo が0未満の場合は、通知時刻に関係なく動画の全てのタスクに一致する
+func taskPrefix(vid string, minutesAgo time.Duration) string {
+	if minutesAgo < 0 {
+		return fmt.Sprintf("v%s-", vid)
+	}
+	return fmt.Sprintf("v%s-%dm-", vid, int64(minutesAgo.Minutes()))
+}
+
+// キュー、動画ID、何分前に通知するかからタスク名を生成する
+// 削除、実行済みのタスク名はしばらく再利用できないため、実行時刻も含める
+func (t *Task) TaskName(info *TaskInfo, scheduleTime time.Time) string {
+	return fmt.Sprintf("%s/tasks/%s%d", t.queuePath(info.QueueID), taskPrefix(info.Video.Id, info.MinutesAgo), scheduleTime.Unix())
+}
+
+// タスクの実行時刻を計算する
+// 実行時刻より過去の時間を指定すると、すぐにタスクが実行される
+func scheduleTime(info *TaskInfo) time.Time {
+	v := info.Video
+	if v.LiveStreamingDetails == nil {
+		return time.Now()
+	}
+	vstime, _ := time.Parse("2006-01-02T15:04:05Z", v.LiveStreamingDetails.ScheduledStartTime)
+	return vstime.Add(-info.MinutesAgo)
+}
+
+// タスクの実行時刻が Cloud Tasks に登録できる範囲内か
+func InRange(info *TaskInfo) bool {
+	return time.Until(scheduleTime(info)).Hours()/24 <= maxScheduleDays
+}
+
 // 動画開始時刻の 〇分前 に指定のURLにHTTPリクエストを送るタスクを作成
 // 指定されたURLには 動画ID が付属される
+// 登録したタスクの名前はDBに記録し、削除、置き換え時に使う
+// 実行時刻が31日以降の場合は ErrOutOfRange を返す
 func (t *Task) Create(info *TaskInfo) error {
+	v := info.Video
+	scheduleTime := scheduleTime(info)
+
+	// 31日以上の場合
+	if !InRange(info) {
+		slog.Warn("31日以降のタスクは登録できません",
+			slog.String("video_id", v.Id),
+			slog.String("video_title", v.Snippet.Title),
+		)
+		return ErrOutOfRange
+	}
+
+	name := t.TaskName(info, scheduleTime)
+	err := t.createTask(info, name, scheduleTime)
+	// 削除、実行済みのタスク名はしばらく再利用できないため、名前を変えて登録し直す
+	if errors.Is(err, errTaskNameUsed) {
+		slog.Warn("削除、実行済みのタスク名のため、名前を変えて登録します",
+			slog.String("video_id", v.Id),
+			slog.String("task_name", name),
+		)
+		name = fmt.Sprintf("%s-%d", name, time.Now().Unix())
+		err = t.createTask(info, name, scheduleTime)
+	}
+	if err != nil {
+		slog.Error(err.Error(),
+			slog.String("video_id", v.Id),
+		)
+		return err
+	}
+
+	// 記録できないと削除、置き換えができなくなるため、エラーを返す
+	err = t.DB.SaveCloudTask(db.CloudTask{
+		VideoID:      v.Id,
+		QueueID:      info.QueueID,
+		MinutesAgo:   int64(info.MinutesAgo.Minutes()),
+		Name:         name,
+		ScheduleTime: scheduleTime.UTC(),
+	})
+	if err != nil {
+		slog.Error(err.Error(),
+			slog.String("video_id", v.Id),
+			slog.String("task_name", name),
+		)
+		return err
+	}
+	return nil
+}
+
+// 指定したURLにすぐにHTTPリクエストを送るタスクを作成する
+// 動画に関係しないタスクのため、名前を指定せずDBにも記録しない
+func (t *Task) CreateHTTPTask(queueID string, url string, header map[string]string, body []byte) error {
 	ctx := context.Background()
+	req := &taskspb.CreateTaskRequest{
+		Parent: t.queuePath(queueID),
+		Task: &taskspb.Task{
+			MessageType: &taskspb.Task_HttpRequest{
+				HttpRequest: &taskspb.HttpRequest{
+					HttpMethod: taskspb.HttpMethod_POST,
+					Url:        url,
+					Headers:    header,
+					Body:       body,
+				},
+			},
+		},
+	}
+	return retry.Do(
+		func() error {
+			_, err := t.Client.CreateTask(ctx, req)
+			return err
+		},
+		retry.Attempts(3),
+		retry.Delay(1*time.Second),
+	)
+}
 
-	// 実行時刻より過去の時間を指定すると、すぐにタスクが実行される
+// 同じ名前のタスクが削除、実行済みで、登録できない場合のエラー
+var errTaskNameUsed = errors.New("task name was used by a deleted or executed task")
+
+// 指定した名前でタスクを作成する
+// 同じ名前のタスクが登録済みの場合は登録できたものとして扱う
+func (t *Task) createTask(info *TaskInfo, name string, scheduleTime time.Time) error {
+	ctx := context.Background()
 	v := info.Video
-	scheduleTime := time.Now()
-	if v.LiveStreamingDetails != nil {
-		vstime, _ := time.Parse("2006-01-02T15:04:05Z", v.LiveStreamingDetails.ScheduledStartTime)
-		scheduleTime = vstime.Add(-info.MinutesAgo)
-
-		// 31日以上の場合
-		if time.Until(scheduleTime).Hours()/24 > 30 {
-			slog.Warn("31日以降のタスクは登録できません",
-				slog.String("video_id", v.Id),
-				slog.String("video_title", v.Snippet.Title),
-			)
-			return nil
-		}
-	}
 
 	req := &taskspb.CreateTaskRequest{
-		Parent: fmt.Sprintf("projects/%s/locations/%s/queues/%s", t.projectID, t.locationID, info.QueueID),
+		Parent: t.queuePath(info.QueueID),
 		Task: &taskspb.Task{
+			Name: name,
 			MessageType: &taskspb.Task_HttpRequest{
 				HttpRequest: &taskspb.HttpRequest{
 					HttpMethod: taskspb.HttpMethod_POST,
@@ -80,29 +249,212 @@ func (t *Task) Create(info *TaskInfo) error {
 	slog.Info("CreateTask",
 		slog.String("video_id", v.Id),
 		slog.String("video_title", v.Snippet.Title),
+		slog.String("task_name", name),
 	)
 
 	err := retry.Do(
 		func() error {
 			_, err := t.Client.CreateTask(ctx, req)
-			// 既に登録済みのタスクの場合、警告ログを表示　エラーは返さない
-			if err != nil && strings.Contains(err.Error(), "AlreadyExists") {
-				slog.Warn(err.Error(),
-					slog.String("video_id", v.Id),
-				)
-				return nil
-			}
 			return err
 		},
 		retry.Attempts(3),
 		retry.Delay(1*time.Second),
+		retry.RetryIf(func(err error) bool {
+			return status.Code(err) != codes.AlreadyExists
+		}),
+		retry.LastErrorOnly(true),
 	)
+	if status.Code(err) != codes.AlreadyExists {
+		return err
+	}
+
+	// 同じ名前のタスクがキューに残っている場合は登録済み、残っていない場合は削除、実行済み
+	_, err = t.Client.GetTask(ctx, &taskspb.GetTaskRequest{Name: name})
+	if status.Code(err) == codes.NotFound {
+		return errTaskNameUsed
+	}
 	if err != nil {
-		slog.Error(err.Error(),
-			slog.String("video_id", v.Id),
+		return err
+	}
+	slog.Warn("登録済みのタスクです",
+		slog.String("video_id", v.Id),
+		slog.String("task_name", name),
+	)
+	return nil
+}
+
+// タスクを削除する　既に実行、削除済みのタスクの場合はエラーを返さない
+func (t *Task) deleteTask(name string) error {
+	ctx := context.Background()
+
+	slog.Info("DeleteTask",
+		slog.String("task_name", name),
+	)
+
+	err := retry.Do(
+		func() error {
+			return t.Client.DeleteTask(ctx, &taskspb.DeleteTaskRequest{Name: name})
+		},
+		retry.Attempts(3),
+		retry.Delay(1*time.Second),
+		retry.RetryIf(func(err error) bool {
+			return status.Code(err) != codes.NotFound
+		}),
+		retry.LastErrorOnly(true),
+	)
+	if status.Code(err) == codes.NotFound {
+		slog.Warn(err.Error(),
+			slog.String("task_name", name),
 		)
+		return nil
+	}
+	return err
+}
+
+// 指定したキューに登録した動画のタスクを全て削除する
+func (t *Task) Delete(queueID string, vid string) error {
+	tasks, err := t.DB.GetCloudTasks(queueID, vid)
+	if err != nil {
 		return err
 	}
 
+	for _, ct := range tasks {
+		if err := t.deleteTask(ct.Name); err != nil {
+			slog.Error(err.Error(),
+				slog.String("video_id", vid),
+			)
+			return err
+		}
+		if err := t.DB.DeleteCloudTask(ct); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// 同じ動画、同じ通知時刻の登録済みタスクを削除して、新しい実行時刻でタスクを作成する
+// 実行時刻が変わっていない場合は何もしない
+func (t *Task) Replace(info *TaskInfo) error {
+	v := info.Video
+
+	tasks, err := t.DB.GetCloudTasks(info.QueueID, v.Id)
+	if err != nil {
+		return err
+	}
+
+	for _, ct := range tasks {
+		if ct.MinutesAgo != int64(info.MinutesAgo.Minutes()) {
+			continue
+		}
+		if ct.ScheduleTime.Equal(scheduleTime(info).UTC().Truncate(time.Second)) {
+			return nil
+		}
+		if err := t.deleteTask(ct.Name); err != nil {
+			slog.Error(err.Error(),
+				slog.String("video_id", v.Id),
+			)
+			return err
+		}
+	}
+
+	return t.Create(info)
+}
+
+// キューに残っているタスクのうち、DBに記録されていないタスクを記録する
+// cloud_tasks テーブルを作成する前に登録されたタスクを削除、置き換えの対象にするため、一度だけ実行する
+// 動画と通知時刻が同じタスクが複数ある場合は、重複しているタスクを削除する
+// 記録したタスクの数を返す
+func (t *Task) ImportTasks(queueID string) (int, error) {
+	ctx := context.Background()
+
+	recorded, err := t.DB.GetCloudTaskNames(queueID)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	it := t.Client.ListTasks(ctx, &taskspb.ListTasksRequest{Parent: t.queuePath(queueID)})
+	for {
+		task, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return count, err
+		}
+		if slices.Contains(recorded, task.Name) {
+			continue
+		}
+
+		ct, err := t.legacyTask(queueID, task)
+		if err != nil {
+			// 記録できないタスクは削除せずに残す
+			slog.Warn(err.Error(),
+				slog.String("task_name", task.Name),
+			)
+			continue
+		}
+
+		exists, err := t.DB.GetCloudTasks(queueID, ct.VideoID)
+		if err != nil {
+			return count, err
+		}
+		if slices.ContainsFunc(exists, func(e db.CloudTask) bool { return e.MinutesAgo == ct.MinutesAgo }) {
+			if err := t.deleteTask(task.Name); err != nil {
+				return count, err
+			}
+			continue
+		}
+
+		if err := t.DB.SaveCloudTask(ct); err != nil {
+			return count, err
+		}
+		recorded = append(recorded, task.Name)
+		count++
+	}
+	return count, nil
+}
+
+// DBに記録されていないタスクから、記録する情報を作成する
+// 通知時刻はタスク名から、名前を指定していないタスクは動画の公開予定時刻から求める
+func (t *Task) legacyTask(queueID string, task *taskspb.Task) (db.CloudTask, error) {
+	req := task.GetHttpRequest()
+	if req == nil {
+		return db.CloudTask{}, fmt.Errorf("HTTPリクエストのタスクではありません")
+	}
+	u, err := url.Parse(req.Url)
+	if err != nil {
+		return db.CloudTask{}, err
+	}
+	vid := u.Query().Get("v")
+	if vid == "" {
+		return db.CloudTask{}, fmt.Errorf("動画IDがありません %s", req.Url)
+	}
+	scheduleTime := task.ScheduleTime.AsTime().UTC()
+
+	ct := db.CloudTask{
+		VideoID:      vid,
+		QueueID:      queueID,
+		Name:         task.Name,
+		ScheduleTime: scheduleTime,
+	}
+
+	var m int64
+	prefix := t.queuePath(queueID) + "/tasks/" + taskPrefix(vid, -1)
+	if rest, ok := strings.CutPrefix(task.Name, prefix); ok {
+		if _, err := fmt.Sscanf(rest, "%dm-", &m); err == nil {
+			ct.MinutesAgo = m
+			return ct, nil
+		}
+	}
+
+	videos, err := t.DB.GetVideos([]string{vid})
+	if err != nil {
+		return db.CloudTask{}, err
+	}
+	if len(videos) == 0 || videos[0].StartTime.IsZero() {
+		return db.CloudTask{}, fmt.Errorf("動画の公開予定時刻がわかりません %s", vid)
+	}
+	ct.MinutesAgo = int64(videos[0].StartTime.Sub(scheduleTime).Round(time.Minute).Minutes())
+	return ct, nil
+}
@@ -6,16 +6,25 @@ import (
 	"testing"
 	"time"
 
+	taskspb "cloud.google.com/go/cloudtasks/apiv2/cloudtaskspb"
+	"github.com/aopontann/niji-tuu/internal/common/db"
 	"google.golang.org/api/youtube/v3"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 func TestCreateTask(t *testing.T) {
 	// videos := loadTestVideos(t)
 
-	task, err := NewTask()
+	cdb, err := db.NewDB(os.Getenv("DSN"))
 	if err != nil {
 		t.Fatal(err)
 	}
+	defer cdb.Close()
+	task, err := NewTask(cdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer task.Close()
 
 	videos := loadTestVideos(t)
 
@@ -47,3 +56,47 @@ func loadTestVideos(t *testing.T) *youtube.VideoListResponse {
 	}
 	return &videos
 }
+
+func TestLegacyTask(t *testing.T) {
+	ctask := &Task{projectID: "p", locationID: "l"}
+	info := &TaskInfo{QueueID: "q", MinutesAgo: time.Hour}
+	info.Video.Id = "EgaXyUcsM48"
+	scheduleTime := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	task := &taskspb.Task{
+		Name: ctask.TaskName(info, scheduleTime),
+		MessageType: &taskspb.Task_HttpRequest{
+			HttpRequest: &taskspb.HttpRequest{Url: "https://example.com/song?v=EgaXyUcsM48"},
+		},
+		ScheduleTime: timestamppb.New(scheduleTime),
+	}
+	ct, err := ctask.legacyTask("q", task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct.VideoID != "EgaXyUcsM48" || ct.MinutesAgo != 60 || ct.Name != task.Name || !ct.ScheduleTime.Equal(scheduleTime) {
+		t.Errorf("legacyTask() = %+v", ct)
+	}
+
+	task.MessageType = &taskspb.Task_HttpRequest{HttpRequest: &taskspb.HttpRequest{Url: "https://example.com/song"}}
+	if _, err := ctask.legacyTask("q", task); err == nil {
+		t.Error("legacyTask() without video id expected error")
+	}
+}
+
+func TestNewReminder(t *testing.T) {
+	info := &TaskInfo{
+		Video: youtube.Video{
+			Id:                   "EgaXyUcsM48",
+			LiveStreamingDetails: &youtube.VideoLiveStreamingDetails{ScheduledStartTime: "2026-12-01T12:00:00Z"},
+		},
+		QueueID:    "song",
+		URL:        "https://example.com/song",
+		MinutesAgo: time.Hour,
+	}
+	r := NewReminder(info, db.ReminderStatusDeferred)
+	want := time.Date(2026, 12, 1, 11, 0, 0, 0, time.UTC)
+	if !r.ScheduleTime.Equal(want) || r.MinutesAgo != 60 || r.Status != db.ReminderStatusDeferred {
+		t.Errorf("NewReminder() = %+v", r)
+	}
+}
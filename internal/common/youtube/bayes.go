@@ -0,0 +1,184 @@
+package youtube
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+
+	"github.com/aopontann/niji-tuu/internal/common/normalize"
+)
+
+// タイトルの文字 n-gram から歌動画かを判定するナイーブベイズ分類器
+// 日本語は単語の区切りがないため、形態素解析の代わりに文字 n-gram を使う
+type SongModel struct {
+	// 1〜N文字の n-gram を特徴量にする
+	N int `json:"n"`
+	// クラスごとの学習した動画数
+	Docs [2]int `json:"docs"`
+	// クラスごとの n-gram の合計出現回数
+	Total [2]int `json:"total"`
+	// n-gram ごとのクラス別出現回数
+	Counts map[string]*[2]int `json:"counts"`
+}
+
+// ラベル付きのタイトル
+type LabeledTitle struct {
+	Title  string
+	IsSong bool
+}
+
+// 評価結果
+type Evaluation struct {
+	TP, FP, FN, TN int
+}
+
+const (
+	classNotSong = 0
+	classSong    = 1
+)
+
+func NewSongModel(n int) *SongModel {
+	return &SongModel{N: n, Counts: make(map[string]*[2]int)}
+}
+
+// 正規化したタイトルの 1〜n 文字の n-gram を返す
+func NGrams(title string, n int) []string {
+	runes := []rune(normalize.String(title))
+	var grams []string
+	for size := 1; size <= n; size++ {
+		for i := 0; i+size <= len(runes); i++ {
+			grams = append(grams, string(runes[i:i+size]))
+		}
+	}
+	return grams
+}
+
+func class(isSong bool) int {
+	if isSong {
+		return classSong
+	}
+	return classNotSong
+}
+
+// タイトルを1件学習する
+func (m *SongModel) Train(title string, isSong bool) {
+	c := class(isSong)
+	m.Docs[c]++
+	for _, g := range NGrams(title, m.N) {
+		if m.Counts[g] == nil {
+			m.Counts[g] = &[2]int{}
+		}
+		m.Counts[g][c]++
+		m.Total[c]++
+	}
+}
+
+// タイトルが歌動画である確率を返す　学習していない場合は 0.5 を返す
+func (m *SongModel) Probability(title string) float64 {
+	if m.Docs[classSong] == 0 || m.Docs[classNotSong] == 0 {
+		return 0.5
+	}
+
+	docs := float64(m.Docs[classSong] + m.Docs[classNotSong])
+	vocab := float64(len(m.Counts))
+	var logp [2]float64
+	for c := range logp {
+		logp[c] = math.Log(float64(m.Docs[c]) / docs)
+	}
+	for _, g := range NGrams(title, m.N) {
+		counts, ok := m.Counts[g]
+		if !ok {
+			// 学習データにない n-gram はどちらのクラスにも同じ影響のため無視する
+			continue
+		}
+		for c := range logp {
+			// ラプラススムージング
+			logp[c] += math.Log((float64(counts[c]) + 1) / (float64(m.Total[c]) + vocab))
+		}
+	}
+
+	// 桁あふれしないように対数の差からシグモイドで確率を求める
+	return 1 / (1 + math.Exp(logp[classNotSong]-logp[classSong]))
+}
+
+// 歌動画と判定する確率の閾値で評価する
+func (m *SongModel) Evaluate(samples []LabeledTitle, threshold float64) Evaluation {
+	var e Evaluation
+	for _, s := range samples {
+		predicted := m.Probability(s.Title) >= threshold
+		switch {
+		case predicted && s.IsSong:
+			e.TP++
+		case predicted && !s.IsSong:
+			e.FP++
+		case !predicted && s.IsSong:
+			e.FN++
+		default:
+			e.TN++
+		}
+	}
+	return e
+}
+
+// 歌動画と判定した動画のうち、実際に歌動画だった割合
+func (e Evaluation) Precision() float64 {
+	if e.TP+e.FP == 0 {
+		return 0
+	}
+	return float64(e.TP) / float64(e.TP+e.FP)
+}
+
+// 歌動画のうち、歌動画と判定できた割合
+func (e Evaluation) Recall() float64 {
+	if e.TP+e.FN == 0 {
+		return 0
+	}
+	return float64(e.TP) / float64(e.TP+e.FN)
+}
+
+func (e Evaluation) Accuracy() float64 {
+	total := e.TP + e.FP + e.FN + e.TN
+	if total == 0 {
+		return 0
+	}
+	return float64(e.TP+e.TN) / float64(total)
+}
+
+// 学習用と評価用に分割する　同じ seed の場合は同じ分割になる
+func SplitSamples(samples []LabeledTitle, testRatio float64, seed int64) (train []LabeledTitle, test []LabeledTitle) {
+	shuffled := append([]LabeledTitle(nil), samples...)
+	r := rand.New(rand.NewSource(seed))
+	r.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+
+	n := int(float64(len(shuffled)) * testRatio)
+	return shuffled[n:], shuffled[:n]
+}
+
+// モデルをJSONファイルに保存する
+func (m *SongModel) Save(path string) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// JSONファイルからモデルを読み込む
+func LoadSongModel(path string) (*SongModel, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m SongModel
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to load song model %s: %w", path, err)
+	}
+	if m.Counts == nil {
+		m.Counts = make(map[string]*[2]int)
+	}
+	return &m, nil
+}
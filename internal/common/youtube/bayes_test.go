@@ -0,0 +1,79 @@
+package youtube
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+var labeledTitles = []LabeledTitle{
+	{"【歌ってみた】ヒバナ / 葛葉", true},
+	{"【オリジナル曲】Dear My Friend 【MV】", true},
+	{"シャルル / cover", true},
+	{"【歌ってみた】KING", true},
+	{"【APEX】ランクマッチ", false},
+	{"【雑談】週末の話", false},
+	{"【マイクラ】建築するぞ", false},
+	{"【APEX】大会練習", false},
+}
+
+func TestNGrams(t *testing.T) {
+	got := NGrams("ｶﾊﾞｰ!", 2)
+	want := []string{"か", "ば", "かば"}
+	if len(got) != len(want) {
+		t.Fatalf("NGrams() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("NGrams() = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestSongModel(t *testing.T) {
+	m := NewSongModel(2)
+	if p := m.Probability("歌ってみた"); p != 0.5 {
+		t.Errorf("untrained Probability() = %v, want 0.5", p)
+	}
+
+	for _, s := range labeledTitles {
+		m.Train(s.Title, s.IsSong)
+	}
+
+	if p := m.Probability("【歌ってみた】ロキ"); p < 0.5 {
+		t.Errorf("Probability(song) = %v, want >= 0.5", p)
+	}
+	if p := m.Probability("【APEX】ソロランク"); p >= 0.5 {
+		t.Errorf("Probability(not song) = %v, want < 0.5", p)
+	}
+
+	e := m.Evaluate(labeledTitles, 0.5)
+	if e.Precision() != 1 || e.Recall() != 1 || e.Accuracy() != 1 {
+		t.Errorf("unexpected evaluation %+v", e)
+	}
+
+	path := filepath.Join(t.TempDir(), "model.json")
+	if err := m.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadSongModel(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Probability("シャルル") != m.Probability("シャルル") {
+		t.Error("loaded model returns different probability")
+	}
+}
+
+func TestSplitSamples(t *testing.T) {
+	train, test := SplitSamples(labeledTitles, 0.25, 1)
+	if len(train) != 6 || len(test) != 2 {
+		t.Errorf("SplitSamples() = %d, %d, want 6, 2", len(train), len(test))
+	}
+
+	train2, _ := SplitSamples(labeledTitles, 0.25, 1)
+	for i := range train {
+		if train[i] != train2[i] {
+			t.Error("SplitSamples() with same seed returns different split")
+		}
+	}
+}
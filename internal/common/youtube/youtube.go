@@ -3,13 +3,17 @@ package youtube
 import (
 	"context"
 	"encoding/xml"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/aopontann/niji-tuu/internal/common/yturl"
 	"github.com/hashicorp/go-retryablehttp"
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/api/option"
@@ -242,23 +246,36 @@ func (y *Youtube) Videos(vids []string) ([]yt.Video, error) {
 	return rlist, nil
 }
 
-// 歌ってみた動画のタイトルによく含まれるキーワードが 指定した動画に含まれているか
-func (y *Youtube) FindSongKeyword(video yt.Video) bool {
-	songWords := []string{"cover", "歌って", "歌わせて", "Original Song", "オリジナル曲", "オリジナル楽曲", "オリジナルソング", "MV", "Music Video"}
-	for _, word := range songWords {
-		if strings.Contains(strings.ToLower(video.Snippet.Title), strings.ToLower(word)) {
-			return true
-		}
+// チャンネルのURLから取得した情報からチャンネルIDを取得する
+// ハンドルのみの場合は Youtube Data API で検索する
+func (y *Youtube) ChannelID(ch yturl.Channel) (string, error) {
+	if ch.ID != "" {
+		return ch.ID, nil
+	}
+	res, err := y.Service.Channels.List([]string{"id"}).ForHandle(ch.Handle).Do()
+	if err != nil {
+		slog.Error(err.Error())
+		return "", err
+	}
+	if len(res.Items) == 0 {
+		return "", fmt.Errorf("チャンネル %s が見つかりません", ch.Handle)
 	}
-	return false
+	return res.Items[0].Id, nil
 }
 
-// 無視するキーワードが 指定した動画に含まれているか
-func (y *Youtube) FindIgnoreKeyword(video yt.Video) bool {
-	for _, word := range []string{"切り抜き", "ラジオ", "くろなん"} {
-		if strings.Contains(strings.ToLower(video.Snippet.Title), strings.ToLower(word)) {
-			return true
-		}
+// ISO 8601 形式の動画時間 例 P1DT2H3M4S
+var durationPattern = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// ISO 8601 形式の動画時間を time.Duration に変換する
+func ParseDuration(iso string) (time.Duration, error) {
+	m := durationPattern.FindStringSubmatch(iso)
+	if m == nil {
+		return 0, fmt.Errorf("invalid duration %q", iso)
+	}
+
+	var n [4]int
+	for i := range n {
+		n[i], _ = strconv.Atoi(m[i+1])
 	}
-	return false
+	return time.Duration(n[0])*24*time.Hour + time.Duration(n[1])*time.Hour + time.Duration(n[2])*time.Minute + time.Duration(n[3])*time.Second, nil
 }
@@ -162,30 +162,6 @@ func TestVideos(t *testing.T) {
 	}
 }
 
-func TestFindSongKeyword(t *testing.T) {
-	yt, err := NewYoutube(os.Getenv("YOUTUBE_API_KEY"))
-	if err != nil {
-		t.Fatal(err.Error())
-	}
-
-	var res youtube.VideoListResponse
-	data, err := os.ReadFile("testdata/videos.json")
-	if err != nil {
-		t.Error(err)
-	}
-	if err := json.Unmarshal([]byte(data), &res); err != nil {
-		t.Error(err)
-	}
-
-	for _, v := range res.Items {
-		if yt.FindSongKeyword(*v) {
-			t.Log("TRUE:", v.Snippet.Title)
-		} else {
-			t.Log("FALSE:", v.Snippet.Title)
-		}
-	}
-}
-
 func TestRSSFeed(t *testing.T) {
 	SetUp()
 	yt, err := NewYoutube(os.Getenv("YOUTUBE_API_KEY"))
@@ -0,0 +1,143 @@
+package yturl
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrInvalidVideo   = errors.New("動画のURLまたは動画IDを指定してください 例 https://www.youtube.com/watch?v=C56ImfpThK0")
+	ErrInvalidChannel = errors.New("チャンネルのURLまたはチャンネルIDを指定してください 例 https://www.youtube.com/channel/UC... https://www.youtube.com/@handle")
+)
+
+var (
+	videoIDPattern   = regexp.MustCompile(`^[0-9A-Za-z_-]{11}$`)
+	channelIDPattern = regexp.MustCompile(`^UC[0-9A-Za-z_-]{22}$`)
+	// ハンドルは日本語などの文字も使える 例 @にじさんじ
+	handlePattern = regexp.MustCompile(`^@[\p{L}\p{M}\p{N}_.\-·]{3,30}$`)
+)
+
+// 動画IDをパスに含むURLの接頭辞 例 https://www.youtube.com/shorts/C56ImfpThK0
+var videoPathPrefixes = []string{"/shorts/", "/live/", "/embed/", "/v/", "/e/"}
+
+// 動画のURLか動画IDから動画IDを取得する
+//
+//	C56ImfpThK0
+//	https://www.youtube.com/watch?v=C56ImfpThK0&t=10s
+//	https://youtu.be/C56ImfpThK0?si=xxxx
+//	https://www.youtube.com/shorts/C56ImfpThK0
+//	https://www.youtube.com/live/C56ImfpThK0
+//	https://www.youtube.com/embed/C56ImfpThK0
+//	https://m.youtube.com/watch?v=C56ImfpThK0, https://music.youtube.com/watch?v=C56ImfpThK0
+func VideoID(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if videoIDPattern.MatchString(s) {
+		return s, nil
+	}
+
+	u, err := parse(s)
+	if err != nil {
+		return "", ErrInvalidVideo
+	}
+
+	var vid string
+	switch {
+	case u.Host == "youtu.be":
+		vid, _, _ = strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	case isYoutubeHost(u.Host):
+		if u.Path == "/watch" {
+			vid = u.Query().Get("v")
+			break
+		}
+		for _, prefix := range videoPathPrefixes {
+			if rest, ok := strings.CutPrefix(u.Path, prefix); ok {
+				vid, _, _ = strings.Cut(rest, "/")
+				break
+			}
+		}
+	}
+
+	if !videoIDPattern.MatchString(vid) {
+		return "", ErrInvalidVideo
+	}
+	return vid, nil
+}
+
+// カンマ区切りの動画IDか動画のURLから、動画IDのリストを取得する
+// クエリパラメータ v で受け取る値に使う
+func VideoIDs(s string) ([]string, error) {
+	var vids []string
+	for _, v := range strings.Split(s, ",") {
+		vid, err := VideoID(v)
+		if err != nil {
+			return nil, err
+		}
+		vids = append(vids, vid)
+	}
+	return vids, nil
+}
+
+// チャンネルのURLから取得した情報
+// ハンドルのみ指定された場合は ID が空になるため、YouTube Data API で ID を取得する
+type Channel struct {
+	ID     string
+	Handle string
+}
+
+// チャンネルのURLかチャンネルID、ハンドルからチャンネルの情報を取得する
+//
+//	UCxxxxxxxxxxxxxxxxxxxxxx
+//	@handle
+//	https://www.youtube.com/channel/UCxxxxxxxxxxxxxxxxxxxxxx
+//	https://www.youtube.com/@handle
+func ParseChannel(s string) (Channel, error) {
+	s = strings.TrimSpace(s)
+	if channelIDPattern.MatchString(s) {
+		return Channel{ID: s}, nil
+	}
+	if handlePattern.MatchString(s) {
+		return Channel{Handle: s}, nil
+	}
+
+	u, err := parse(s)
+	if err != nil || !isYoutubeHost(u.Host) {
+		return Channel{}, ErrInvalidChannel
+	}
+
+	first, rest, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	if first == "channel" {
+		cid, _, _ := strings.Cut(rest, "/")
+		if channelIDPattern.MatchString(cid) {
+			return Channel{ID: cid}, nil
+		}
+	}
+	// @ を含むパスはエンコードされている場合がある
+	if handle, err := url.PathUnescape(first); err == nil && handlePattern.MatchString(handle) {
+		return Channel{Handle: handle}, nil
+	}
+	return Channel{}, ErrInvalidChannel
+}
+
+// スキームを省略したURLも受け付ける 例 youtu.be/C56ImfpThK0
+func parse(s string) (*url.URL, error) {
+	if !strings.Contains(s, "://") {
+		s = "https://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	u.Host = strings.ToLower(u.Hostname())
+	return u, nil
+}
+
+func isYoutubeHost(host string) bool {
+	host = strings.TrimPrefix(host, "www.")
+	switch host {
+	case "youtube.com", "m.youtube.com", "music.youtube.com", "youtube-nocookie.com":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package yturl
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestVideoID(t *testing.T) {
+	valid := []string{
+		"C56ImfpThK0",
+		" C56ImfpThK0 ",
+		"https://www.youtube.com/watch?v=C56ImfpThK0",
+		"https://www.youtube.com/watch?feature=share&v=C56ImfpThK0&t=10s",
+		"https://m.youtube.com/watch?v=C56ImfpThK0",
+		"https://music.youtube.com/watch?v=C56ImfpThK0&list=RDAMVM",
+		"youtube.com/watch?v=C56ImfpThK0",
+		"https://youtu.be/C56ImfpThK0",
+		"https://youtu.be/C56ImfpThK0?si=abcdef&t=1",
+		"https://www.youtube.com/shorts/C56ImfpThK0",
+		"https://youtube.com/shorts/C56ImfpThK0?feature=share",
+		"https://www.youtube.com/live/C56ImfpThK0?si=abcdef",
+		"https://www.youtube.com/embed/C56ImfpThK0",
+		"https://www.youtube-nocookie.com/embed/C56ImfpThK0",
+		"HTTPS://WWW.YOUTUBE.COM/watch?v=C56ImfpThK0",
+	}
+	for _, s := range valid {
+		if vid, err := VideoID(s); err != nil || vid != "C56ImfpThK0" {
+			t.Errorf("VideoID(%q) = %q %v", s, vid, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"C56ImfpThK",
+		"https://www.youtube.com/",
+		"https://www.youtube.com/watch?v=",
+		"https://www.youtube.com/watch?v=C56ImfpThK0xx",
+		"https://www.youtube.com/@handle",
+		"https://example.com/watch?v=C56ImfpThK0",
+		"https://youtu.be/",
+	}
+	for _, s := range invalid {
+		if vid, err := VideoID(s); err != ErrInvalidVideo {
+			t.Errorf("VideoID(%q) = %q %v, want ErrInvalidVideo", s, vid, err)
+		}
+	}
+}
+
+func TestVideoIDs(t *testing.T) {
+	vids, err := VideoIDs("C56ImfpThK0,https://youtu.be/dQw4w9WgXcQ")
+	if err != nil || !slices.Equal(vids, []string{"C56ImfpThK0", "dQw4w9WgXcQ"}) {
+		t.Errorf("VideoIDs() = %v %v", vids, err)
+	}
+	if _, err := VideoIDs("C56ImfpThK0,xxx"); err == nil {
+		t.Error("VideoIDs() expected error")
+	}
+}
+
+func TestParseChannel(t *testing.T) {
+	const cid = "UCSFCh5NL4qXrAy9u-u2lX3g"
+	tests := []struct {
+		in   string
+		want Channel
+	}{
+		{cid, Channel{ID: cid}},
+		{"https://www.youtube.com/channel/" + cid, Channel{ID: cid}},
+		{"https://www.youtube.com/channel/" + cid + "/videos", Channel{ID: cid}},
+		{"@kuzuha", Channel{Handle: "@kuzuha"}},
+		{"https://www.youtube.com/@kuzuha", Channel{Handle: "@kuzuha"}},
+		{"https://m.youtube.com/@kuzuha/streams", Channel{Handle: "@kuzuha"}},
+		{"https://www.youtube.com/%40kuzuha", Channel{Handle: "@kuzuha"}},
+		{"@にじさんじ", Channel{Handle: "@にじさんじ"}},
+		{"https://www.youtube.com/@%E3%81%AB%E3%81%98%E3%81%95%E3%82%93%E3%81%98", Channel{Handle: "@にじさんじ"}},
+	}
+	for _, tt := range tests {
+		if got, err := ParseChannel(tt.in); err != nil || got != tt.want {
+			t.Errorf("ParseChannel(%q) = %+v %v", tt.in, got, err)
+		}
+	}
+
+	for _, s := range []string{"", "UCxxx", "https://www.youtube.com/watch?v=C56ImfpThK0", "https://example.com/@kuzuha", "https://www.youtube.com/channel/xxx", "@ab", "@にじ さんじ"} {
+		if _, err := ParseChannel(s); err != ErrInvalidChannel {
+			t.Errorf("ParseChannel(%q) = %v, want ErrInvalidChannel", s, err)
+		}
+	}
+}
@@ -0,0 +1,410 @@
+package discordbot
+
+import (
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// スラッシュコマンドの定義と実行する処理
+// サブコマンドを持つ場合は SubCommands を、持たない場合は Options と Handler を指定する
+type Command struct {
+	Name        string
+	Description string
+	Options     []*discordgo.ApplicationCommandOption
+	SubCommands []*Command
+	Handler     CommandHandler
+
+	// 以下はトップレベルのコマンドのみ指定できる
+	// コマンドを使用できる既定の権限　サーバーの設定で変更できるため、AdminOnly でも確認する
+	DefaultMemberPermissions int64
+	// true の場合は管理者のロールを持つメンバーのみ実行できる
+	AdminOnly bool
+}
+
+// 実行されたコマンドの情報
+type CommandRequest struct {
+	Interaction *discordgo.Interaction
+	// 実行されたコマンド（サブコマンドの場合はサブコマンド）のオプション
+	Options InteractionOption
+}
+
+// 返信するメッセージを返す
+type CommandHandler func(req CommandRequest) string
+
+// Discord に登録するコマンドの定義を作成する
+func (c *Command) ApplicationCommand() *discordgo.ApplicationCommand {
+	cmd := &discordgo.ApplicationCommand{
+		Type:        discordgo.ChatApplicationCommand,
+		Name:        c.Name,
+		Description: c.Description,
+		Options:     c.options(),
+	}
+	if c.DefaultMemberPermissions != 0 {
+		permissions := c.DefaultMemberPermissions
+		cmd.DefaultMemberPermissions = &permissions
+	}
+	return cmd
+}
+
+func (c *Command) options() []*discordgo.ApplicationCommandOption {
+	if len(c.SubCommands) == 0 {
+		return c.Options
+	}
+	var options []*discordgo.ApplicationCommandOption
+	for _, sub := range c.SubCommands {
+		t := discordgo.ApplicationCommandOptionSubCommand
+		if len(sub.SubCommands) != 0 {
+			t = discordgo.ApplicationCommandOptionSubCommandGroup
+		}
+		options = append(options, &discordgo.ApplicationCommandOption{
+			Name:        sub.Name,
+			Description: sub.Description,
+			Type:        t,
+			Options:     sub.options(),
+		})
+	}
+	return options
+}
+
+// 登録されている全てのコマンドの定義
+func ApplicationCommands() []*discordgo.ApplicationCommand {
+	var cmds []*discordgo.ApplicationCommand
+	for _, c := range Commands {
+		cmds = append(cmds, c.ApplicationCommand())
+	}
+	return cmds
+}
+
+// 実行されたコマンドを名前から探し、実行するコマンドとそのオプションを返す
+func FindCommand(commands []*Command, data InteractionData) (*Command, InteractionOption, error) {
+	opt := InteractionOption{Name: data.Name, Options: data.Options}
+	cmd := findCommand(commands, data.Name)
+	if cmd == nil {
+		return nil, opt, fmt.Errorf("未対応のコマンドです %s", data.Name)
+	}
+
+	for len(cmd.SubCommands) != 0 {
+		var sub *Command
+		for _, o := range opt.Options {
+			if sub = findCommand(cmd.SubCommands, o.Name); sub != nil {
+				opt = o
+				break
+			}
+		}
+		if sub == nil {
+			return nil, opt, fmt.Errorf("サブコマンドを指定してください")
+		}
+		cmd = sub
+	}
+	return cmd, opt, nil
+}
+
+func findCommand(commands []*Command, name string) *Command {
+	for _, c := range commands {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// スラッシュコマンドを実行し、返信するメッセージを返す
+func Dispatch(interaction *discordgo.Interaction, data InteractionData) string {
+	cmd, opt, err := FindCommand(Commands, data)
+	if err != nil {
+		return err.Error()
+	}
+	if findCommand(Commands, data.Name).AdminOnly && !IsAdmin(interaction.Member, adminRoleIDs()) {
+		auditRejected(interaction, commandPath(data), "not admin")
+		return permissionDeniedMessage
+	}
+	return cmd.Handler(CommandRequest{Interaction: interaction, Options: opt})
+}
+
+// 処理結果から返信するメッセージを作成する
+func reply(msg string, err error) string {
+	if err != nil {
+		return "失敗しました：" + err.Error()
+	}
+	return truncate(msg, maxMessageLength)
+}
+
+var Commands = []*Command{
+	{
+		Name:        "song",
+		Description: "歌みた動画の管理",
+		// 通知タスクを登録、削除するため
+		DefaultMemberPermissions: discordgo.PermissionManageServer,
+		AdminOnly:                true,
+		SubCommands: []*Command{
+			{
+				Name:        "add",
+				Description: "歌みた動画の追加",
+				Options:     []*discordgo.ApplicationCommandOption{songURLOption},
+				Handler: func(req CommandRequest) string {
+					if err := AddSong(req.Options.StringValue("url")); err != nil {
+						return "登録に失敗しました：" + err.Error()
+					}
+					return "登録しました"
+				},
+			},
+			{
+				Name:        "list",
+				Description: "通知予定の歌みた動画の一覧",
+				Handler: func(req CommandRequest) string {
+					return reply(ListSongs())
+				},
+			},
+			{
+				Name:        "remove",
+				Description: "歌みた動画の通知を取り消す",
+				Options:     []*discordgo.ApplicationCommandOption{songURLOption},
+				Handler: func(req CommandRequest) string {
+					return reply(RemoveSong(req.Options.StringValue("url")))
+				},
+			},
+			{
+				Name:        "reschedule",
+				Description: "最新の公開予定時刻で通知を登録し直す",
+				Options:     []*discordgo.ApplicationCommandOption{songURLOption},
+				Handler: func(req CommandRequest) string {
+					return reply(RescheduleSong(req.Options.StringValue("url")))
+				},
+			},
+			{
+				Name:        "info",
+				Description: "歌動画の判定結果と登録されている通知を表示する",
+				Options:     []*discordgo.ApplicationCommandOption{songURLOption},
+				Handler: func(req CommandRequest) string {
+					return reply(SongInfo(req.Options.StringValue("url")))
+				},
+			},
+		},
+	},
+	{
+		Name:        "keyword",
+		Description: "キーワードの管理",
+		// チャンネル、ロールを作成、削除するため
+		DefaultMemberPermissions: discordgo.PermissionManageChannels | discordgo.PermissionManageRoles,
+		AdminOnly:                true,
+		SubCommands: []*Command{
+			{
+				Name:        "add",
+				Description: "キーワードを登録する",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Name:        "name",
+						Description: "キーワード名（チャンネル、ロールの名前になる）",
+						Type:        discordgo.ApplicationCommandOptionString,
+						Required:    true,
+					},
+					{
+						Name:        "expression",
+						Description: "通知する条件式 例 APEX OR VALORANT",
+						Type:        discordgo.ApplicationCommandOptionString,
+						Required:    true,
+					},
+					{
+						Name:        "category_id",
+						Description: "追加先のカテゴリID",
+						Type:        discordgo.ApplicationCommandOptionString,
+						Required:    true,
+					},
+				},
+				Handler: func(req CommandRequest) string {
+					if err := AddKeyword(req.Options.StringValue("name"), req.Options.StringValue("expression"), req.Options.StringValue("category_id")); err != nil {
+						return "登録に失敗しました：" + err.Error()
+					}
+					return "登録しました"
+				},
+			},
+			{
+				Name:        "list",
+				Description: "登録されているキーワードの一覧",
+				Handler: func(req CommandRequest) string {
+					return reply(ListKeywords())
+				},
+			},
+			{
+				Name:        "show",
+				Description: "キーワードの設定を表示する",
+				Options:     []*discordgo.ApplicationCommandOption{keywordNameOption},
+				Handler: func(req CommandRequest) string {
+					return reply(ShowKeyword(req.Options.StringValue("name")))
+				},
+			},
+			{
+				Name:        "remove",
+				Description: "キーワードを削除する",
+				Options: []*discordgo.ApplicationCommandOption{
+					keywordNameOption,
+					{
+						Name:        "archive_category_id",
+						Description: "チャンネルの移動先のカテゴリID（指定しない場合はチャンネルを残す）",
+						Type:        discordgo.ApplicationCommandOptionString,
+					},
+					{
+						Name:        "delete_role",
+						Description: "ロールを削除する",
+						Type:        discordgo.ApplicationCommandOptionBoolean,
+					},
+				},
+				Handler: func(req CommandRequest) string {
+					err := RemoveKeyword(req.Options.StringValue("name"), req.Options.StringValue("archive_category_id"), req.Options.BoolValue("delete_role"))
+					return reply("削除しました", err)
+				},
+			},
+			{
+				Name:        "rename",
+				Description: "キーワードの名前を変更する",
+				Options: []*discordgo.ApplicationCommandOption{
+					keywordNameOption,
+					{
+						Name:        "new_name",
+						Description: "新しい名前",
+						Type:        discordgo.ApplicationCommandOptionString,
+						Required:    true,
+					},
+				},
+				Handler: func(req CommandRequest) string {
+					err := RenameKeyword(req.Options.StringValue("name"), req.Options.StringValue("new_name"))
+					return reply("名前を変更しました", err)
+				},
+			},
+			{
+				Name:        "preview",
+				Description: "条件式が直近の動画に一致するか確認する",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Name:        "expression",
+						Description: "条件式 例 APEX OR VALORANT",
+						Type:        discordgo.ApplicationCommandOptionString,
+						Required:    true,
+					},
+					{
+						Name:        "ignore",
+						Description: "除外する条件式",
+						Type:        discordgo.ApplicationCommandOptionString,
+					},
+					{
+						Name:        "days",
+						Description: "確認する期間（日数、既定は7日）",
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						MinValue:    &minPreviewDays,
+						MaxValue:    maxPreviewDays,
+					},
+					{
+						Name:        "scopes",
+						Description: "検索範囲 title, description, tags, channel をカンマ区切りで指定（既定は title）",
+						Type:        discordgo.ApplicationCommandOptionString,
+					},
+				},
+				Handler: func(req CommandRequest) string {
+					return KeywordPreviewCommand(req.Options)
+				},
+			},
+			conditionCommand("include", "通知する条件式"),
+			conditionCommand("ignore", "除外する条件式"),
+		},
+	},
+	{
+		Name:        "subscribe",
+		Description: "キーワードの通知を登録、解除するパネルをこのチャンネルに作成する",
+		// パネルの作成のみ管理者に限定する　パネルからの登録、解除は全員ができる
+		DefaultMemberPermissions: discordgo.PermissionManageServer,
+		AdminOnly:                true,
+		Handler: func(req CommandRequest) string {
+			if err := CreateSubscribePanel(req.Interaction.ChannelID); err != nil {
+				return "パネルの作成に失敗しました：" + err.Error()
+			}
+			return "パネルを作成しました"
+		},
+	},
+}
+
+var minPreviewDays = 1.0
+
+var songURLOption = &discordgo.ApplicationCommandOption{
+	Name:        "url",
+	Description: "動画のURL",
+	Type:        discordgo.ApplicationCommandOptionString,
+	Required:    true,
+}
+
+var keywordNameOption = &discordgo.ApplicationCommandOption{
+	Name:        "name",
+	Description: "キーワード名",
+	Type:        discordgo.ApplicationCommandOptionString,
+	Required:    true,
+}
+
+// 条件式を追加、削除するサブコマンドグループ
+// kind は include か ignore
+func conditionCommand(kind string, description string) *Command {
+	options := []*discordgo.ApplicationCommandOption{
+		keywordNameOption,
+		{
+			Name:        "expression",
+			Description: "条件式 例 APEX OR VALORANT",
+			Type:        discordgo.ApplicationCommandOptionString,
+			Required:    true,
+		},
+	}
+	channelOptions := []*discordgo.ApplicationCommandOption{
+		keywordNameOption,
+		{
+			Name:        "channel",
+			Description: "チャンネルのURLかハンドル 例 https://www.youtube.com/@handle",
+			Type:        discordgo.ApplicationCommandOptionString,
+			Required:    true,
+		},
+	}
+	handler := func(op string) CommandHandler {
+		return func(req CommandRequest) string {
+			err := EditKeywordCondition(kind, op, req.Options.StringValue("name"), req.Options.StringValue("expression"))
+			return reply("更新しました", err)
+		}
+	}
+	// チャンネルの指定は channel:チャンネルID の条件式として登録する
+	channelHandler := func(op string) CommandHandler {
+		return func(req CommandRequest) string {
+			expr, err := channelExpression(req.Options.StringValue("channel"))
+			if err != nil {
+				return reply("", err)
+			}
+			err = EditKeywordCondition(kind, op, req.Options.StringValue("name"), expr)
+			return reply("更新しました（条件式 "+expr+"）", err)
+		}
+	}
+	return &Command{
+		Name:        kind,
+		Description: description + "の管理",
+		SubCommands: []*Command{
+			{
+				Name:        "add",
+				Description: description + "を追加する",
+				Options:     options,
+				Handler:     handler("add"),
+			},
+			{
+				Name:        "remove",
+				Description: description + "を削除する",
+				Options:     options,
+				Handler:     handler("remove"),
+			},
+			{
+				Name:        "add-channel",
+				Description: description + "にチャンネルを追加する",
+				Options:     channelOptions,
+				Handler:     channelHandler("add"),
+			},
+			{
+				Name:        "remove-channel",
+				Description: description + "からチャンネルを削除する",
+				Options:     channelOptions,
+				Handler:     channelHandler("remove"),
+			},
+		},
+	}
+}
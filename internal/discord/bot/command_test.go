@@ -0,0 +1,80 @@
+package discordbot
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestFindCommand(t *testing.T) {
+	// /keyword include remove name:apex expression:VALORANT
+	body := `{"name":"keyword","options":[{"name":"include","type":2,"options":[{"name":"remove","type":1,"options":[
+		{"name":"expression","type":3,"value":"VALORANT"},
+		{"name":"name","type":3,"value":"apex"}]}]}]}`
+	var data InteractionData
+	if err := json.Unmarshal([]byte(body), &data); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd, opt, err := FindCommand(Commands, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd.Name != "remove" || cmd.Handler == nil || opt.StringValue("name") != "apex" || opt.StringValue("expression") != "VALORANT" {
+		t.Errorf("unexpected command %s %+v", cmd.Name, opt)
+	}
+
+	cmd, _, err = FindCommand(Commands, InteractionData{Name: "subscribe"})
+	if err != nil || cmd.Name != "subscribe" {
+		t.Errorf("FindCommand(subscribe) = %v %v", cmd, err)
+	}
+
+	if _, _, err := FindCommand(Commands, InteractionData{Name: "unknown"}); err == nil {
+		t.Error("FindCommand(unknown) expected error")
+	}
+	if _, _, err := FindCommand(Commands, InteractionData{Name: "song"}); err == nil {
+		t.Error("FindCommand(song) without subcommand expected error")
+	}
+}
+
+// 全てのコマンドに処理が登録されていて、Discord の制限を満たしているか
+func TestCommands(t *testing.T) {
+	var walk func(path string, cmds []*Command)
+	walk = func(path string, cmds []*Command) {
+		names := make(map[string]bool)
+		for _, c := range cmds {
+			if names[c.Name] {
+				t.Errorf("%s%s is duplicated", path, c.Name)
+			}
+			names[c.Name] = true
+			if len([]rune(c.Description)) > 100 {
+				t.Errorf("%s%s description is too long", path, c.Name)
+			}
+			if len(c.SubCommands) == 0 && c.Handler == nil {
+				t.Errorf("%s%s has no handler", path, c.Name)
+			}
+			walk(path+c.Name+" ", c.SubCommands)
+		}
+	}
+	walk("/", Commands)
+
+	var keyword *discordgo.ApplicationCommand
+	for _, c := range ApplicationCommands() {
+		if c.Name == "keyword" {
+			keyword = c
+		}
+	}
+	if keyword == nil {
+		t.Fatal("keyword command not found")
+	}
+	for _, o := range keyword.Options {
+		want := discordgo.ApplicationCommandOptionSubCommand
+		if o.Name == "include" || o.Name == "ignore" {
+			want = discordgo.ApplicationCommandOptionSubCommandGroup
+		}
+		if o.Type != want {
+			t.Errorf("/keyword %s type = %v, want %v", o.Name, o.Type, want)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package discordbot
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/aopontann/niji-tuu/internal/common/task"
+	discordmessage "github.com/aopontann/niji-tuu/internal/discord/message"
+)
+
+// ワーカーが処理するインタラクションの有効期間
+// Cloud Tasks は依頼後すぐに送信するため、それより古いリクエストは再送されたものとみなす
+const maxInteractionAge = 5 * time.Minute
+
+// インタラクションの処理をワーカーに依頼する
+// テストで差し替えられるように変数にする
+var enqueueInteraction = enqueueInteractionTask
+
+// インタラクションは3秒以内に応答する必要があるため、処理をワーカーに依頼して「考え中」の応答のみ返す
+// ワーカーが処理を行い、結果で応答を編集する
+// Cloud Functions はレスポンスを返した後のCPUが制限されるため、リクエスト内では処理しない
+func DeferResponse(w http.ResponseWriter, r *http.Request, body []byte, ephemeral bool) {
+	var flags discordgo.MessageFlags
+	if ephemeral {
+		flags = discordgo.MessageFlagsEphemeral
+	}
+	deferInteraction(w, r, body, discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Flags: flags},
+	})
+}
+
+// ボタンを押したメッセージを、ワーカーが処理結果に書き換える
+func DeferUpdate(w http.ResponseWriter, r *http.Request, body []byte) {
+	deferInteraction(w, r, body, discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	})
+}
+
+func deferInteraction(w http.ResponseWriter, r *http.Request, body []byte, response discordgo.InteractionResponse) {
+	// 依頼できなかった場合は「考え中」のまま残らないように、エラーを返信する
+	if err := enqueueInteraction(r.Header, body); err != nil {
+		slog.Error(err.Error())
+		writeResponse(w, discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "処理を受け付けられませんでした：" + err.Error(),
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+		return
+	}
+	writeResponse(w, response)
+}
+
+// Discord からのリクエストを、署名のヘッダーごと Cloud Tasks でワーカーに送る
+// ワーカーでも署名を検証するため、ワーカーのURLが知られても偽のインタラクションは処理されない
+// 環境変数 SCHEDULER が local の場合は、リクエスト後も処理を続けられるため goroutine で処理する
+func enqueueInteractionTask(header http.Header, body []byte) error {
+	if os.Getenv("SCHEDULER") == "local" {
+		go func() {
+			if err := RunInteraction(body); err != nil {
+				slog.Error(err.Error())
+			}
+		}()
+		return nil
+	}
+
+	ctask, err := task.NewTask(nil)
+	if err != nil {
+		return err
+	}
+	defer ctask.Close()
+
+	return ctask.CreateHTTPTask(os.Getenv("DISCORD_INTERACTION_QUEUE_ID"), os.Getenv("DISCORD_INTERACTION_URL"), map[string]string{
+		"Content-Type":          "application/json",
+		"X-Signature-Ed25519":   header.Get("X-Signature-Ed25519"),
+		"X-Signature-Timestamp": header.Get("X-Signature-Timestamp"),
+	}, body)
+}
+
+// Handler が依頼したインタラクションを処理するワーカー
+// 処理に失敗しても Cloud Tasks に再実行されないように、署名が正しければ200を返す
+func WorkerHandler(w http.ResponseWriter, r *http.Request) {
+	body, ok := verifyRequest(w, r)
+	if !ok {
+		return
+	}
+	// 署名済みのリクエストを再送されても処理しないように、古いリクエストは拒否する
+	if err := checkTimestamp(r.Header.Get("X-Signature-Timestamp"), time.Now()); err != nil {
+		slog.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err := RunInteraction(body); err != nil {
+		slog.Error(err.Error())
+	}
+}
+
+// 署名の時刻が maxInteractionAge 以内か　時計のずれを考慮して、未来の時刻は1分まで許容する
+func checkTimestamp(timestamp string, now time.Time) error {
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("署名の時刻が不正です %q", timestamp)
+	}
+	t := time.Unix(sec, 0)
+	if now.Sub(t) > maxInteractionAge || t.Sub(now) > time.Minute {
+		return fmt.Errorf("署名の時刻が有効期間外です %s", t.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// インタラクションを処理して、結果で応答を編集する
+func RunInteraction(body []byte) error {
+	var interaction discordgo.Interaction
+	if err := json.Unmarshal(body, &interaction); err != nil {
+		return err
+	}
+
+	switch interaction.Type {
+	case discordgo.InteractionApplicationCommand:
+		var data InteractionData
+		// discordgo.Interaction.Data に Name などのフィールドがないため、[]byteに変換して自作の構造体にマッピングする
+		jsonData, err := json.Marshal(interaction.Data)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(jsonData, &data); err != nil {
+			return err
+		}
+		return editResponse(&interaction, Dispatch(&interaction, data))
+
+	case discordgo.InteractionMessageComponent:
+		componentData := interaction.MessageComponentData()
+
+		if IsSubscribeCustomID(componentData.CustomID) {
+			added, removed, err := ToggleKeywordRoles(interaction.GuildID, interaction.Member, componentData.Values)
+			return editResponse(&interaction, toggleResultMessage(added, removed, err))
+		}
+
+		decision, vid, ok := discordmessage.ParseReviewCustomID(componentData.CustomID)
+		if !ok {
+			return fmt.Errorf("未対応の操作です %s", componentData.CustomID)
+		}
+
+		// 判定すると告知タスクが登録されるため、管理者のみ操作できる
+		if !IsAdmin(interaction.Member, adminRoleIDs()) {
+			auditRejected(&interaction, "song-review", "not admin")
+			return editResponse(&interaction, permissionDeniedMessage)
+		}
+
+		userID := interactionUserID(&interaction)
+		isSong := decision == discordmessage.ReviewSong
+		if err := ReviewSong(vid, isSong, userID); err != nil {
+			return updateMessage(&interaction, "", fmt.Errorf("判定の登録に失敗しました：%w", err))
+		}
+		if isSong {
+			return updateMessage(&interaction, fmt.Sprintf("<@%s> が歌動画と判定し、告知を登録しました", userID), nil)
+		}
+		return updateMessage(&interaction, fmt.Sprintf("<@%s> が歌動画ではないと判定しました", userID), nil)
+	}
+	return fmt.Errorf("未対応のインタラクションです %d", interaction.Type)
+}
+
+// 「考え中」の応答を処理結果に編集する
+func editResponse(interaction *discordgo.Interaction, content string) error {
+	discord, err := discordgo.New("Bot " + os.Getenv("DISCORD_BOT_TOKEN"))
+	if err != nil {
+		return err
+	}
+	content = truncate(content, maxMessageLength)
+	_, err = discord.InteractionResponseEdit(interaction, &discordgo.WebhookEdit{Content: &content})
+	return err
+}
+
+// ボタンを押したメッセージを処理結果に書き換えて、ボタンを消す
+// 失敗した場合はメッセージを残し、操作したユーザーにのみエラーを表示する
+func updateMessage(interaction *discordgo.Interaction, content string, result error) error {
+	discord, err := discordgo.New("Bot " + os.Getenv("DISCORD_BOT_TOKEN"))
+	if err != nil {
+		return err
+	}
+	if result != nil {
+		_, err = discord.FollowupMessageCreate(interaction, false, &discordgo.WebhookParams{
+			Content: truncate(result.Error(), maxMessageLength),
+			Flags:   discordgo.MessageFlagsEphemeral,
+		})
+		return err
+	}
+	_, err = discord.InteractionResponseEdit(interaction, &discordgo.WebhookEdit{
+		Content:    &content,
+		Components: &[]discordgo.MessageComponent{},
+	})
+	return err
+}
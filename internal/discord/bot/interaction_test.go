@@ -0,0 +1,144 @@
+package discordbot
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Discord と同じ方法で署名したリクエストを作成する
+func signedRequest(t *testing.T, body string) *http.Request {
+	t.Helper()
+	return signedRequestAt(t, body, "1760745600")
+}
+
+func signedRequestAt(t *testing.T, body string, timestamp string) *http.Request {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DISCORD_PUBLIC_KEY", hex.EncodeToString(pub))
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	r.Header.Set("X-Signature-Ed25519", hex.EncodeToString(ed25519.Sign(priv, []byte(timestamp+body))))
+	r.Header.Set("X-Signature-Timestamp", timestamp)
+	return r
+}
+
+func stubEnqueue(t *testing.T, fn func(header http.Header, body []byte) error) {
+	t.Helper()
+	orig := enqueueInteraction
+	enqueueInteraction = fn
+	t.Cleanup(func() { enqueueInteraction = orig })
+}
+
+func TestHandlerReturnsBeforeWork(t *testing.T) {
+	body := `{"type":2,"id":"1","token":"token","data":{"name":"song","options":[{"name":"list","type":1}]}}`
+	r := signedRequest(t, body)
+
+	release := make(chan struct{})
+	done := make(chan struct{})
+	var enqueued []byte
+	stubEnqueue(t, func(header http.Header, b []byte) error {
+		if header.Get("X-Signature-Ed25519") == "" || header.Get("X-Signature-Timestamp") == "" {
+			t.Error("signature headers are not forwarded")
+		}
+		enqueued = b
+		// ワーカーの処理は応答を返した後に行われる
+		go func() {
+			<-release
+			close(done)
+		}()
+		return nil
+	})
+
+	w := httptest.NewRecorder()
+	Handler(w, r)
+	select {
+	case <-done:
+		t.Fatal("work finished before the handler returned")
+	default:
+	}
+
+	var res discordgo.InteractionResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Type != discordgo.InteractionResponseDeferredChannelMessageWithSource || res.Data.Flags != discordgo.MessageFlagsEphemeral {
+		t.Errorf("unexpected response %s", w.Body.String())
+	}
+	if string(enqueued) != body {
+		t.Errorf("enqueued body = %s", enqueued)
+	}
+
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("work did not run")
+	}
+}
+
+func TestHandlerEnqueueError(t *testing.T) {
+	r := signedRequest(t, `{"type":2,"id":"1","token":"token","data":{"name":"song"}}`)
+	stubEnqueue(t, func(header http.Header, b []byte) error {
+		return errors.New("queue is unavailable")
+	})
+
+	w := httptest.NewRecorder()
+	Handler(w, r)
+
+	var res discordgo.InteractionResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Type != discordgo.InteractionResponseChannelMessageWithSource || !strings.Contains(res.Data.Content, "queue is unavailable") {
+		t.Errorf("unexpected response %s", w.Body.String())
+	}
+}
+
+func TestWorkerHandlerRejectsInvalidSignature(t *testing.T) {
+	r := signedRequest(t, `{"type":2,"id":"1","token":"token","data":{"name":"song"}}`)
+	r.Header.Set("X-Signature-Timestamp", "0")
+
+	w := httptest.NewRecorder()
+	WorkerHandler(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("WorkerHandler() = %d", w.Code)
+	}
+}
+
+func TestWorkerHandlerRejectsReplay(t *testing.T) {
+	old := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	r := signedRequestAt(t, `{"type":2,"id":"1","token":"token","data":{"name":"song"}}`, old)
+
+	w := httptest.NewRecorder()
+	WorkerHandler(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("WorkerHandler() = %d", w.Code)
+	}
+}
+
+func TestCheckTimestamp(t *testing.T) {
+	now := time.Unix(1760745600, 0)
+	for _, ts := range []string{"1760745600", "1760745330", "1760745650"} {
+		if err := checkTimestamp(ts, now); err != nil {
+			t.Errorf("checkTimestamp(%s) error: %v", ts, err)
+		}
+	}
+	for _, ts := range []string{"", "abc", "1760745200", "1760745900"} {
+		if err := checkTimestamp(ts, now); err == nil {
+			t.Errorf("checkTimestamp(%q) expected error", ts)
+		}
+	}
+}
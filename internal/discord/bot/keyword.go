@@ -0,0 +1,258 @@
+package discordbot
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/aopontann/niji-tuu/internal/common/db"
+	"github.com/aopontann/niji-tuu/internal/common/match"
+	"github.com/aopontann/niji-tuu/internal/common/youtube"
+	"github.com/aopontann/niji-tuu/internal/common/yturl"
+)
+
+// Discordのメッセージの最大文字数
+const maxMessageLength = 2000
+
+func ListKeywords() (string, error) {
+	cdb, err := db.NewDB(os.Getenv("DSN"))
+	if err != nil {
+		return "", err
+	}
+	defer cdb.Close()
+
+	keywords, err := cdb.GetKeywords()
+	if err != nil {
+		return "", err
+	}
+	if len(keywords) == 0 {
+		return "登録されているキーワードはありません", nil
+	}
+
+	var lines []string
+	for _, k := range keywords {
+		lines = append(lines, fmt.Sprintf("- %s <#%s> 条件 %d件 / 除外 %d件", k.Name, k.ChannelID, len(k.Include), len(k.Ignore)))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func ShowKeyword(name string) (string, error) {
+	cdb, err := db.NewDB(os.Getenv("DSN"))
+	if err != nil {
+		return "", err
+	}
+	defer cdb.Close()
+
+	keyword, err := getKeyword(cdb, name)
+	if err != nil {
+		return "", err
+	}
+	return FormatKeyword(keyword), nil
+}
+
+// キーワードの設定を表示するメッセージを作成する
+func FormatKeyword(k *db.Keyword) string {
+	lines := []string{
+		"**" + k.Name + "**",
+		fmt.Sprintf("チャンネル: <#%s>", k.ChannelID),
+		fmt.Sprintf("ロール: <@&%s>", k.RoleID),
+		"条件: " + formatList(k.Include),
+		"除外: " + formatList(k.Ignore),
+		"検索範囲: " + formatList(k.Scopes),
+	}
+	if len(k.AllowChannels) != 0 || len(k.AllowGroups) != 0 {
+		lines = append(lines, "通知するチャンネル: "+formatList(k.AllowChannels), "通知するグループ: "+formatList(k.AllowGroups))
+	}
+	if len(k.DenyChannels) != 0 || len(k.DenyGroups) != 0 {
+		lines = append(lines, "除外するチャンネル: "+formatList(k.DenyChannels), "除外するグループ: "+formatList(k.DenyGroups))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func formatList(list []string) string {
+	if len(list) == 0 {
+		return "なし"
+	}
+	return "`" + strings.Join(list, "`, `") + "`"
+}
+
+// キーワードを削除する
+// archiveCategoryID を指定した場合はチャンネルをそのカテゴリに移動し、deleteRole が true の場合はロールを削除する
+func RemoveKeyword(name string, archiveCategoryID string, deleteRole bool) error {
+	cdb, err := db.NewDB(os.Getenv("DSN"))
+	if err != nil {
+		return err
+	}
+	defer cdb.Close()
+
+	keyword, err := getKeyword(cdb, name)
+	if err != nil {
+		return err
+	}
+
+	// 通知が止まるように先にDBから削除する
+	if err := cdb.DeleteKeyword(name); err != nil {
+		return err
+	}
+	// パネルはDBの内容で作成するため、チャンネル、ロールの変更が全て終わってから更新する
+	// 途中で失敗してもキーワードは削除済みのため、更新する
+	defer refreshSubscribePanels()
+
+	if archiveCategoryID == "" && !deleteRole {
+		return nil
+	}
+	discord, err := discordgo.New("Bot " + os.Getenv("DISCORD_BOT_TOKEN"))
+	if err != nil {
+		return err
+	}
+	if archiveCategoryID != "" {
+		_, err := discord.ChannelEditComplex(keyword.ChannelID, &discordgo.ChannelEdit{ParentID: archiveCategoryID})
+		if err != nil {
+			return fmt.Errorf("キーワードは削除しましたが、チャンネルの移動に失敗しました %w", err)
+		}
+	}
+	if deleteRole {
+		if err := discord.GuildRoleDelete(os.Getenv("DISCORD_GUILD_ID"), keyword.RoleID); err != nil {
+			return fmt.Errorf("キーワードは削除しましたが、ロールの削除に失敗しました %w", err)
+		}
+	}
+	return nil
+}
+
+// キーワードの名前を変更する　チャンネル名、ロール名も変更する
+func RenameKeyword(name string, newName string) error {
+	if newName == "" || newName == name {
+		return fmt.Errorf("新しい名前を指定してください")
+	}
+
+	cdb, err := db.NewDB(os.Getenv("DSN"))
+	if err != nil {
+		return err
+	}
+	defer cdb.Close()
+
+	keyword, err := getKeyword(cdb, name)
+	if err != nil {
+		return err
+	}
+	if _, err := cdb.GetKeyword(newName); err == nil {
+		return fmt.Errorf("キーワード %s は既に登録されています", newName)
+	} else if err != sql.ErrNoRows {
+		return err
+	}
+
+	if err := cdb.RenameKeyword(name, newName); err != nil {
+		return err
+	}
+	// パネルはDBの内容で作成するため、チャンネル、ロールの変更が全て終わってから更新する
+	// 途中で失敗しても名前は変更済みのため、更新する
+	defer refreshSubscribePanels()
+
+	discord, err := discordgo.New("Bot " + os.Getenv("DISCORD_BOT_TOKEN"))
+	if err != nil {
+		return err
+	}
+	if _, err := discord.ChannelEdit(keyword.ChannelID, &discordgo.ChannelEdit{Name: newName}); err != nil {
+		return fmt.Errorf("名前は変更しましたが、チャンネル名の変更に失敗しました %w", err)
+	}
+	if _, err := discord.GuildRoleEdit(os.Getenv("DISCORD_GUILD_ID"), keyword.RoleID, &discordgo.RoleParams{Name: newName}); err != nil {
+		return fmt.Errorf("名前は変更しましたが、ロール名の変更に失敗しました %w", err)
+	}
+	return nil
+}
+
+// キーワードの条件式、除外する条件式を追加、削除する
+// kind は include か ignore、op は add か remove
+func EditKeywordCondition(kind string, op string, name string, expr string) error {
+	cdb, err := db.NewDB(os.Getenv("DSN"))
+	if err != nil {
+		return err
+	}
+	defer cdb.Close()
+
+	keyword, err := getKeyword(cdb, name)
+	if err != nil {
+		return err
+	}
+
+	switch kind {
+	case "include":
+		keyword.Include, err = editConditions(keyword.Include, op, expr)
+		if err == nil && len(keyword.Include) == 0 {
+			err = fmt.Errorf("条件式が1つもなくなるため削除できません")
+		}
+	case "ignore":
+		keyword.Ignore, err = editConditions(keyword.Ignore, op, expr)
+	default:
+		err = fmt.Errorf("未対応の種類です %s", kind)
+	}
+	if err != nil {
+		return err
+	}
+
+	return cdb.UpdateKeyword(keyword, kind)
+}
+
+// チャンネルのURLかチャンネルID、ハンドルから、そのチャンネルの動画に一致する条件式を作成する
+// ハンドルの場合は YouTube Data API でチャンネルIDを取得する
+func channelExpression(s string) (string, error) {
+	ch, err := yturl.ParseChannel(s)
+	if err != nil {
+		return "", err
+	}
+	if ch.ID == "" {
+		yt, err := youtube.NewYoutube(os.Getenv("YOUTUBE_API_KEY"))
+		if err != nil {
+			return "", err
+		}
+		if ch.ID, err = yt.ChannelID(ch); err != nil {
+			return "", err
+		}
+	}
+	// チャンネルIDは - で始まる場合があるため、除外の指定と区別できるように引用符で囲む
+	return fmt.Sprintf(`channel:"%s"`, ch.ID), nil
+}
+
+// 条件式のリストに追加、削除する
+func editConditions(list []string, op string, expr string) ([]string, error) {
+	switch op {
+	case "add":
+		// 通知時に一致チェックができなくならないように、登録前に条件式を検証する
+		if err := match.Validate(expr); err != nil {
+			return nil, err
+		}
+		if slices.Contains(list, expr) {
+			return nil, fmt.Errorf("条件式 %s は既に登録されています", expr)
+		}
+		return append(list, expr), nil
+	case "remove":
+		i := slices.Index(list, expr)
+		if i < 0 {
+			return nil, fmt.Errorf("条件式 %s は登録されていません", expr)
+		}
+		return slices.Delete(list, i, i+1), nil
+	}
+	return nil, fmt.Errorf("未対応の操作です %s", op)
+}
+
+func getKeyword(cdb *db.DB, name string) (*db.Keyword, error) {
+	keyword, err := cdb.GetKeyword(name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("キーワード %s は登録されていません", name)
+	}
+	return keyword, err
+}
+
+// Discordの最大文字数を超える場合は切り詰める
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
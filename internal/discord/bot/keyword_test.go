@@ -0,0 +1,100 @@
+package discordbot
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/aopontann/niji-tuu/internal/common/db"
+	"github.com/aopontann/niji-tuu/internal/common/match"
+)
+
+func TestInteractionOption(t *testing.T) {
+	// /keyword include add name:apex expression:VALORANT
+	body := `{"name":"keyword","options":[{"name":"include","type":2,"options":[{"name":"add","type":1,"options":[
+		{"name":"name","type":3,"value":"apex"},
+		{"name":"expression","type":3,"value":"VALORANT"}]}]}]}`
+
+	var data InteractionData
+	if err := json.Unmarshal([]byte(body), &data); err != nil {
+		t.Fatal(err)
+	}
+	sub := data.Options[0].Options[0]
+	if sub.Name != "add" || sub.StringValue("name") != "apex" || sub.StringValue("expression") != "VALORANT" {
+		t.Errorf("unexpected option %+v", sub)
+	}
+	if sub.StringValue("missing") != "" || sub.BoolValue("missing") {
+		t.Error("missing option should return zero value")
+	}
+
+	body = `{"name":"remove","type":1,"options":[{"name":"delete_role","type":5,"value":true}]}`
+	var opt InteractionOption
+	if err := json.Unmarshal([]byte(body), &opt); err != nil {
+		t.Fatal(err)
+	}
+	if !opt.BoolValue("delete_role") {
+		t.Error("BoolValue(delete_role) = false, want true")
+	}
+}
+
+func TestEditConditions(t *testing.T) {
+	list, err := editConditions([]string{"APEX"}, "add", "VALORANT OR スト6")
+	if err != nil || len(list) != 2 {
+		t.Errorf("add = %v %v", list, err)
+	}
+	if _, err := editConditions(list, "add", "APEX"); err == nil {
+		t.Error("add duplicate expected error")
+	}
+	if _, err := editConditions(list, "add", "(APEX"); err == nil {
+		t.Error("add invalid expression expected error")
+	}
+
+	list, err = editConditions(list, "remove", "APEX")
+	if err != nil || len(list) != 1 || list[0] != "VALORANT OR スト6" {
+		t.Errorf("remove = %v %v", list, err)
+	}
+	if _, err := editConditions(list, "remove", "APEX"); err == nil {
+		t.Error("remove missing expected error")
+	}
+}
+
+func TestChannelExpression(t *testing.T) {
+	const cid = "UC-hM6YJuNYVAmUWxeIr9FeA"
+	expr, err := channelExpression("https://www.youtube.com/channel/" + cid)
+	if err != nil || expr != `channel:"`+cid+`"` {
+		t.Fatalf("channelExpression() = %q %v", expr, err)
+	}
+	e, err := match.Parse(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !e.Match(&match.Document{Channel: "さくらみこ Miko Ch. " + cid}) {
+		t.Errorf("%s does not match the channel", expr)
+	}
+	if e.Match(&match.Document{Title: cid}) {
+		t.Errorf("%s matches the title", expr)
+	}
+
+	if _, err := channelExpression("https://example.com/@kuzuha"); err == nil {
+		t.Error("channelExpression() expected error")
+	}
+}
+
+func TestFormatKeyword(t *testing.T) {
+	msg := FormatKeyword(&db.Keyword{
+		Name:        "apex",
+		ChannelID:   "1",
+		RoleID:      "2",
+		Include:     []string{"APEX"},
+		AllowGroups: []string{"NIJISANJI EN"},
+	})
+	for _, want := range []string{"**apex**", "<#1>", "<@&2>", "`APEX`", "除外: なし", "`NIJISANJI EN`"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("FormatKeyword() does not contain %q\n%s", want, msg)
+		}
+	}
+
+	if s := truncate(strings.Repeat("あ", 10), 5); s != "ああああ…" {
+		t.Errorf("truncate() = %q", s)
+	}
+}
@@ -4,55 +4,86 @@ import (
 	"context"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"os"
-	"strings"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
 
 	"github.com/aopontann/niji-tuu/internal/common/db"
+	"github.com/aopontann/niji-tuu/internal/common/match"
 	"github.com/aopontann/niji-tuu/internal/common/task"
 	"github.com/aopontann/niji-tuu/internal/common/youtube"
+	"github.com/aopontann/niji-tuu/internal/common/yturl"
+	discordmessage "github.com/aopontann/niji-tuu/internal/discord/message"
+	yt "google.golang.org/api/youtube/v3"
 )
 
 type InteractionData struct {
-	GuildID string `json:"guild_id"`
-	ID      string `json:"id"`
-	Name    string `json:"name"`
-	Options []struct {
-		Name    string `json:"name"`
-		Options []struct {
-			Name  string `json:"name"`
-			Type  int    `json:"type"`
-			Value string `json:"value"`
-		} `json:"options"`
-		Type int `json:"type"`
-	} `json:"options"`
-	Type int `json:"type"`
+	GuildID string              `json:"guild_id"`
+	ID      string              `json:"id"`
+	Name    string              `json:"name"`
+	Options []InteractionOption `json:"options"`
+	Type    int                 `json:"type"`
 }
 
-func Handler(w http.ResponseWriter, r *http.Request) {
-	publicKey := os.Getenv("DISCORD_PUBLIC_KEY")
-	publicKeyBytes, err := hex.DecodeString(publicKey)
-	if err != nil {
-		slog.Error("Error decoding hex string: " + err.Error())
-		http.Error(w, "Error decoding hex string", http.StatusInternalServerError)
-		return
+// コマンドのオプション　サブコマンドグループ、サブコマンドの場合は Options に入れ子になる
+type InteractionOption struct {
+	Name    string              `json:"name"`
+	Type    int                 `json:"type"`
+	Value   any                 `json:"value"`
+	Options []InteractionOption `json:"options"`
+}
+
+// 名前を指定して子のオプションを取得する　ない場合は nil を返す
+func (o InteractionOption) Option(name string) *InteractionOption {
+	for i := range o.Options {
+		if o.Options[i].Name == name {
+			return &o.Options[i]
+		}
 	}
+	return nil
+}
 
-	if !discordgo.VerifyInteraction(r, publicKeyBytes) {
-		slog.Error("Invalid request signature")
-		http.Error(w, "invalid request signature", http.StatusUnauthorized)
-		return
+// 名前を指定して文字列のオプションの値を取得する　ない場合は空文字を返す
+func (o InteractionOption) StringValue(name string) string {
+	opt := o.Option(name)
+	if opt == nil || opt.Value == nil {
+		return ""
+	}
+	if s, ok := opt.Value.(string); ok {
+		return s
 	}
+	return fmt.Sprint(opt.Value)
+}
 
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		slog.Error("Error reading request body: " + err.Error())
-		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+// 名前を指定して整数のオプションの値を取得する　ない場合は ok に false を返す
+func (o InteractionOption) IntValue(name string) (int, bool) {
+	opt := o.Option(name)
+	if opt == nil {
+		return 0, false
+	}
+	// JSONの数値は float64 になる
+	f, ok := opt.Value.(float64)
+	return int(f), ok
+}
+
+// 名前を指定して真偽値のオプションの値を取得する　ない場合は false を返す
+func (o InteractionOption) BoolValue(name string) bool {
+	opt := o.Option(name)
+	if opt == nil {
+		return false
+	}
+	b, _ := opt.Value.(bool)
+	return b
+}
+
+func Handler(w http.ResponseWriter, r *http.Request) {
+	body, ok := verifyRequest(w, r)
+	if !ok {
 		return
 	}
 
@@ -77,49 +108,59 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 処理はワーカーで行い、ここでは「考え中」の応答のみ返す
 	if interaction.Type == 2 {
-		var data InteractionData
-		// discordgo.Interaction.Data に Name などのフィールドがないため、[]byteに変換して自作の構造体にマッピングする
-		jsonData, err := json.Marshal(interaction.Data)
-		if err != nil {
-			http.Error(w, "Error marshalling request body", http.StatusInternalServerError)
+		// 管理用のコマンドのため、結果は実行したユーザーにのみ表示する
+		DeferResponse(w, r, body, true)
+		return
+	}
+
+	// ボタンが押された、メニューが選択された場合
+	if interaction.Type == discordgo.InteractionMessageComponent {
+		componentData := interaction.MessageComponentData()
+
+		if IsSubscribeCustomID(componentData.CustomID) {
+			DeferResponse(w, r, body, true)
 			return
 		}
-		if err := json.Unmarshal(jsonData, &data); err != nil {
-			http.Error(w, "Error unmarshalling request body", http.StatusInternalServerError)
+
+		if _, _, ok := discordmessage.ParseReviewCustomID(componentData.CustomID); !ok {
+			SendMessage(w, "未対応の操作です")
 			return
 		}
 
-		if data.Name == "song" {
-			subCmdInfo := data.Options[0]
+		// 権限がない場合はメッセージを書き換えずに、操作したユーザーにのみ拒否したことを表示する
+		if !IsAdmin(interaction.Member, adminRoleIDs()) {
+			DeferResponse(w, r, body, true)
+			return
+		}
+		DeferUpdate(w, r, body)
+	}
+}
 
-			if subCmdInfo.Name == "add" {
-				url := subCmdInfo.Options[0].Value
-				err := AddSong(url)
+// 署名を検証して、リクエストのボディを返す
+func verifyRequest(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	publicKey := os.Getenv("DISCORD_PUBLIC_KEY")
+	publicKeyBytes, err := hex.DecodeString(publicKey)
+	if err != nil {
+		slog.Error("Error decoding hex string: " + err.Error())
+		http.Error(w, "Error decoding hex string", http.StatusInternalServerError)
+		return nil, false
+	}
 
-				if err != nil {
-					SendMessage(w, "登録に失敗しました："+err.Error())
-				} else {
-					SendMessage(w, "登録しました")
-				}
-			}
-		}
-		if data.Name == "keyword" {
-			subCmdInfo := data.Options[0]
-
-			if subCmdInfo.Name == "add" {
-				keyword := subCmdInfo.Options[0].Value
-				categoryID := subCmdInfo.Options[1].Value
-				err := AddKeyword(keyword, categoryID)
-
-				if err != nil {
-					SendMessage(w, "登録に失敗しました："+err.Error())
-				} else {
-					SendMessage(w, "登録しました")
-				}
-			}
-		}
+	if !discordgo.VerifyInteraction(r, publicKeyBytes) {
+		slog.Error("Invalid request signature")
+		http.Error(w, "invalid request signature", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		slog.Error("Error reading request body: " + err.Error())
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return nil, false
 	}
+	return body, true
 }
 
 func SendMessage(w http.ResponseWriter, content string) {
@@ -141,86 +182,196 @@ func SendMessage(w http.ResponseWriter, content string) {
 	w.WriteHeader(http.StatusOK)
 }
 
+// 応答を書き込み、すぐにDiscordに届くようにフラッシュする
+func writeResponse(w http.ResponseWriter, response discordgo.InteractionResponse) bool {
+	resp, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, "Error marshalling response", http.StatusInternalServerError)
+		return false
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resp)
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return true
+}
+
 func AddSong(url string) error {
-	// urlが https://www.youtube.com/watch?v=C56ImfpThK0 の形式であるため、=で分割して2つ目の要素を取得
-	vid := strings.Split(url, "=")[1]
+	vid, err := yturl.VideoID(url)
+	if err != nil {
+		return err
+	}
 
 	yt, err := youtube.NewYoutube(os.Getenv("YOUTUBE_API_KEY"))
 	if err != nil {
 		return err
 	}
 
-	ctask, err := task.NewTask()
+	videos, err := yt.Videos([]string{vid})
 	if err != nil {
 		return err
 	}
+	if len(videos) == 0 {
+		return fmt.Errorf("動画が見つかりません")
+	}
 
-	videos, err := yt.Videos([]string{vid})
+	return scheduleSong(videos[0])
+}
+
+// 歌動画か判定した結果を学習データとして保存する
+// 歌動画の場合は告知タスクを登録し、歌動画ではない場合は登録済みの告知タスクを削除する
+// 歌動画ではない場合は、削除された動画でも判定できるように YouTube から取得せず videos テーブルの情報で保存する
+func ReviewSong(vid string, isSong bool, userID string) error {
+	cdb, err := db.NewDB(os.Getenv("DSN"))
+	if err != nil {
+		return err
+	}
+	defer cdb.Close()
+
+	label := db.SongLabel{
+		VideoID: vid,
+		IsSong:  isSong,
+		UserID:  userID,
+	}
+
+	if isSong {
+		yt, err := youtube.NewYoutube(os.Getenv("YOUTUBE_API_KEY"))
+		if err != nil {
+			return err
+		}
+		videos, err := yt.Videos([]string{vid})
+		if err != nil {
+			return err
+		}
+		if len(videos) == 0 {
+			return fmt.Errorf("動画が見つかりません")
+		}
+		v := videos[0]
+		if err := scheduleSong(v); err != nil {
+			return err
+		}
+		label.Title = v.Snippet.Title
+		label.Description = v.Snippet.Description
+		label.Tags = v.Snippet.Tags
+	} else {
+		if err := unscheduleSong(vid); err != nil {
+			return err
+		}
+		videos, err := cdb.GetVideos([]string{vid})
+		if err != nil {
+			return err
+		}
+		if len(videos) != 0 {
+			label.Title = videos[0].Title
+		}
+	}
+
+	return cdb.SaveSongLabel(label)
+}
+
+// 歌みた告知タスクを登録する
+func scheduleSong(v yt.Video) error {
+	cdb, err := db.NewDB(os.Getenv("DSN"))
+	if err != nil {
+		return err
+	}
+	defer cdb.Close()
+	ctask, err := task.NewScheduler(cdb)
 	if err != nil {
 		return err
 	}
+	defer ctask.Close()
 
 	taskInfoFCM := &task.TaskInfo{
-		Video:      videos[0],
+		Video:      v,
 		QueueID:    os.Getenv("SONG_QUEUE_ID"),
 		URL:        os.Getenv("SONG_URL"),
 		MinutesAgo: time.Minute * 5,
 	}
 	taskInfoDiscord := &task.TaskInfo{
-		Video:      videos[0],
+		Video:      v,
 		QueueID:    os.Getenv("SONG_QUEUE_ID"),
 		URL:        os.Getenv("SONG_DISCORD_URL"),
 		MinutesAgo: time.Hour * 1,
 	}
 
-	if err := ctask.Create(taskInfoFCM); err != nil {
+	if err := ctask.Replace(taskInfoFCM); err != nil {
 		slog.Error(err.Error())
 		return err
 	}
-	if err := ctask.Create(taskInfoDiscord); err != nil {
+	if err := ctask.Replace(taskInfoDiscord); err != nil {
 		slog.Error(err.Error())
-		return err
+		return fmt.Errorf("アプリへの通知は登録しましたが、Discordへの通知の登録に失敗しました %w", err)
 	}
 
 	return nil
 }
 
-func AddKeyword(keyword string, categoryID string) error {
+// 登録済みの歌みた告知タスクを削除する
+func unscheduleSong(vid string) error {
+	cdb, err := db.NewDB(os.Getenv("DSN"))
+	if err != nil {
+		return err
+	}
+	defer cdb.Close()
+	ctask, err := task.NewScheduler(cdb)
+	if err != nil {
+		return err
+	}
+	defer ctask.Close()
+	return ctask.Delete(os.Getenv("SONG_QUEUE_ID"), vid)
+}
+
+// キーワードを登録する
+// チャンネル、ロールは name で作成し、expression を通知する条件式として登録する
+func AddKeyword(name string, expression string, categoryID string) error {
+	// 通知時に一致チェックができなくならないように、登録前に条件式を検証する
+	if err := match.Validate(expression); err != nil {
+		return err
+	}
+
 	guildID := os.Getenv("DISCORD_GUILD_ID")
 	discord, err := discordgo.New("Bot " + os.Getenv("DISCORD_BOT_TOKEN"))
 	if err != nil {
 		return err
 	}
 
-	channel, err := discord.GuildChannelCreate(guildID, keyword, discordgo.ChannelTypeGuildText)
+	channel, err := discord.GuildChannelCreate(guildID, name, discordgo.ChannelTypeGuildText)
 	if err != nil {
 		return err
 	}
 
+	// 途中で失敗した場合は、作成済みのチャンネル、ロールを手動で整理できるようにどこまで成功したかを返す
 	_, err = discord.ChannelEditComplex(channel.ID, &discordgo.ChannelEdit{ParentID: categoryID})
 	if err != nil {
-		return err
+		return fmt.Errorf("チャンネル <#%s> は作成しましたが、カテゴリへの移動に失敗しました %w", channel.ID, err)
 	}
 
-	role, err := discord.GuildRoleCreate(guildID, &discordgo.RoleParams{Name: keyword})
+	role, err := discord.GuildRoleCreate(guildID, &discordgo.RoleParams{Name: name})
 	if err != nil {
-		return err
+		return fmt.Errorf("チャンネル <#%s> は作成しましたが、ロールの作成に失敗しました %w", channel.ID, err)
 	}
 
 	cdb, err := db.NewDB(os.Getenv("DSN"))
 	if err != nil {
-		return err
+		return fmt.Errorf("チャンネル <#%s> とロール <@&%s> は作成しましたが、キーワードの登録に失敗しました %w", channel.ID, role.ID, err)
 	}
+	defer cdb.Close()
 
 	_, err = cdb.Service.NewInsert().Model(&db.Keyword{
-		Name:      keyword,
+		Name:      name,
 		RoleID:    role.ID,
 		ChannelID: channel.ID,
-		Include:   []string{keyword},
+		Include:   []string{expression},
+		Scopes:    []string{match.ScopeTitle},
 	}).Exec(context.Background())
 	if err != nil {
-		return err
+		return fmt.Errorf("チャンネル <#%s> とロール <@&%s> は作成しましたが、キーワードの登録に失敗しました %w", channel.ID, role.ID, err)
 	}
 
+	refreshSubscribePanels()
 	return nil
 }
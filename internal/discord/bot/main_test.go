@@ -0,0 +1,31 @@
+package discordbot
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestWriteResponse(t *testing.T) {
+	w := httptest.NewRecorder()
+	ok := writeResponse(w, discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Flags: discordgo.MessageFlagsEphemeral},
+	})
+	if !ok || !w.Flushed {
+		t.Errorf("writeResponse() = %v, flushed = %v", ok, w.Flushed)
+	}
+
+	var res discordgo.InteractionResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Type != discordgo.InteractionResponseDeferredChannelMessageWithSource || res.Data.Flags != discordgo.MessageFlagsEphemeral {
+		t.Errorf("unexpected response %s", w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+}
@@ -0,0 +1,95 @@
+package discordbot
+
+import (
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/aopontann/niji-tuu/internal/common/db"
+)
+
+// 権限がない場合に返信するメッセージ
+const permissionDeniedMessage = "このコマンドを実行する権限がありません"
+
+// 管理者として扱うロールID　DISCORD_ADMIN_ROLE_IDS にカンマ区切りで指定する
+func adminRoleIDs() []string {
+	var ids []string
+	for _, id := range strings.Split(os.Getenv("DISCORD_ADMIN_ROLE_IDS"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// 管理者のロールを持っているか、サーバーの管理者権限を持っているか
+// サーバー外（DM）から実行された場合は管理者として扱わない
+func IsAdmin(member *discordgo.Member, roleIDs []string) bool {
+	if member == nil {
+		return false
+	}
+	if member.Permissions&discordgo.PermissionAdministrator != 0 {
+		return true
+	}
+	for _, id := range member.Roles {
+		if slices.Contains(roleIDs, id) {
+			return true
+		}
+	}
+	return false
+}
+
+// 実行したユーザーのID
+func interactionUserID(interaction *discordgo.Interaction) string {
+	if interaction.Member != nil && interaction.Member.User != nil {
+		return interaction.Member.User.ID
+	}
+	if interaction.User != nil {
+		return interaction.User.ID
+	}
+	return ""
+}
+
+// 実行されたコマンドの名前　例 /keyword include add
+func commandPath(data InteractionData) string {
+	path := []string{"/" + data.Name}
+	options := data.Options
+	for len(options) != 0 {
+		opt := options[0]
+		if opt.Type != int(discordgo.ApplicationCommandOptionSubCommand) && opt.Type != int(discordgo.ApplicationCommandOptionSubCommandGroup) {
+			break
+		}
+		path = append(path, opt.Name)
+		options = opt.Options
+	}
+	return strings.Join(path, " ")
+}
+
+// 権限がなく拒否した操作を監査ログに記録する　記録に失敗しても拒否はしているため、ログのみ出す
+func auditRejected(interaction *discordgo.Interaction, action string, reason string) {
+	slog.Warn("permission denied",
+		slog.String("user_id", interactionUserID(interaction)),
+		slog.String("action", action),
+	)
+
+	cdb, err := db.NewDB(os.Getenv("DSN"))
+	if err != nil {
+		slog.Error(err.Error())
+		return
+	}
+	defer cdb.Close()
+
+	err = cdb.SaveAuditLog(db.AuditLog{
+		UserID:  interactionUserID(interaction),
+		GuildID: interaction.GuildID,
+		Action:  action,
+		Result:  db.AuditResultRejected,
+		Reason:  reason,
+	})
+	if err != nil {
+		slog.Error(err.Error())
+	}
+}
@@ -0,0 +1,75 @@
+package discordbot
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestIsAdmin(t *testing.T) {
+	t.Setenv("DISCORD_ADMIN_ROLE_IDS", "1, 2,")
+	roleIDs := adminRoleIDs()
+	if !slices.Equal(roleIDs, []string{"1", "2"}) {
+		t.Fatalf("adminRoleIDs() = %v", roleIDs)
+	}
+
+	tests := []struct {
+		name   string
+		member *discordgo.Member
+		want   bool
+	}{
+		{"dm", nil, false},
+		{"no role", &discordgo.Member{Roles: []string{"3"}}, false},
+		{"admin role", &discordgo.Member{Roles: []string{"3", "2"}}, true},
+		{"administrator", &discordgo.Member{Permissions: discordgo.PermissionAdministrator}, true},
+		{"manage server only", &discordgo.Member{Permissions: discordgo.PermissionManageServer}, false},
+	}
+	for _, tt := range tests {
+		if got := IsAdmin(tt.member, roleIDs); got != tt.want {
+			t.Errorf("IsAdmin(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// ロールが設定されていない場合は管理者権限を持つメンバーのみ
+	if IsAdmin(&discordgo.Member{Roles: []string{""}}, nil) {
+		t.Error("IsAdmin() without admin roles = true")
+	}
+}
+
+func TestCommandPath(t *testing.T) {
+	body := `{"name":"keyword","options":[{"name":"include","type":2,"options":[{"name":"add","type":1,"options":[
+		{"name":"name","type":3,"value":"apex"}]}]}]}`
+	var data InteractionData
+	if err := json.Unmarshal([]byte(body), &data); err != nil {
+		t.Fatal(err)
+	}
+	if p := commandPath(data); p != "/keyword include add" {
+		t.Errorf("commandPath() = %q", p)
+	}
+	if p := commandPath(InteractionData{Name: "subscribe"}); p != "/subscribe" {
+		t.Errorf("commandPath() = %q", p)
+	}
+}
+
+func TestDispatchRejectsNonAdmin(t *testing.T) {
+	t.Setenv("DISCORD_ADMIN_ROLE_IDS", "1")
+	// 監査ログを記録できない場合も拒否はする
+	t.Setenv("DSN", "invalid")
+
+	interaction := &discordgo.Interaction{
+		Member: &discordgo.Member{User: &discordgo.User{ID: "10"}, Roles: []string{"2"}},
+	}
+	data := InteractionData{Name: "song", Options: []InteractionOption{{Name: "list", Type: 1}}}
+	if msg := Dispatch(interaction, data); msg != permissionDeniedMessage {
+		t.Errorf("Dispatch() = %q", msg)
+	}
+
+	// 管理用のコマンドには既定の権限が設定されている
+	for _, c := range ApplicationCommands() {
+		if c.DefaultMemberPermissions == nil || *c.DefaultMemberPermissions == 0 {
+			t.Errorf("/%s has no default member permissions", c.Name)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package discordbot
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aopontann/niji-tuu/internal/common/db"
+	"github.com/aopontann/niji-tuu/internal/common/match"
+	"github.com/aopontann/niji-tuu/internal/common/youtube"
+	discordnotice "github.com/aopontann/niji-tuu/internal/discord/notice"
+	yt "google.golang.org/api/youtube/v3"
+)
+
+const (
+	// プレビューする期間の既定値と上限
+	defaultPreviewDays = 7
+	maxPreviewDays     = 30
+	// YouTube から取得して判定する動画の上限　API の使用量を抑えるため、新しい動画から取得する
+	maxPreviewFetchVideos = 200
+)
+
+// キーワードの判定結果
+type PreviewResult struct {
+	Matched []yt.Video
+	// 条件式に一致したが、除外する条件式で除外された動画の数
+	Ignored int
+	// 取得する動画の上限を超えたため判定しなかった動画の数
+	Skipped int
+}
+
+// /keyword preview の結果を返す
+func KeywordPreviewCommand(opt InteractionOption) string {
+	days, ok := opt.IntValue("days")
+	if !ok {
+		days = defaultPreviewDays
+	}
+	var scopes []string
+	if s := opt.StringValue("scopes"); s != "" {
+		for _, scope := range strings.Split(s, ",") {
+			scopes = append(scopes, strings.TrimSpace(scope))
+		}
+	}
+
+	keyword := db.Keyword{
+		Name:    "preview",
+		Include: []string{opt.StringValue("expression")},
+		Scopes:  scopes,
+	}
+	if ignore := opt.StringValue("ignore"); ignore != "" {
+		keyword.Ignore = []string{ignore}
+	}
+
+	res, err := PreviewKeyword(keyword, days)
+	if err != nil {
+		return "プレビューに失敗しました：" + err.Error()
+	}
+	return truncate(FormatPreview(res, days), maxMessageLength)
+}
+
+// 直近 days 日間に登録された動画に対して、通知時と同じ判定を行う
+func PreviewKeyword(keyword db.Keyword, days int) (*PreviewResult, error) {
+	if days <= 0 || days > maxPreviewDays {
+		return nil, fmt.Errorf("期間は1〜%d日で指定してください", maxPreviewDays)
+	}
+	// 通知時に解析できない条件式は無視されるため、先に検証する
+	for _, expr := range slices.Concat(keyword.Include, keyword.Ignore) {
+		if err := match.Validate(expr); err != nil {
+			return nil, fmt.Errorf("%s: %w", expr, err)
+		}
+	}
+	if err := match.ValidateScopes(keyword.Scopes); err != nil {
+		return nil, err
+	}
+
+	cdb, err := db.NewDB(os.Getenv("DSN"))
+	if err != nil {
+		return nil, err
+	}
+	defer cdb.Close()
+
+	stored, err := cdb.GetVideosSince(time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		return nil, err
+	}
+	if len(stored) == 0 {
+		return &PreviewResult{}, nil
+	}
+
+	// タイトルのみ判定する場合は、DBに保存しているタイトルで判定する
+	if !needsYoutube(keyword) {
+		var videos []yt.Video
+		for _, v := range stored {
+			videos = append(videos, yt.Video{Id: v.ID, Snippet: &yt.VideoSnippet{Title: v.Title}})
+		}
+		return Preview(keyword, videos, nil), nil
+	}
+
+	vtubers, err := cdb.GetVtubers()
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(vtubers))
+	for _, v := range vtubers {
+		names[v.ID] = v.Name
+	}
+
+	// 概要欄、タグ、チャンネルはDBに保存していないため、YouTube から動画情報を取得する
+	skipped := 0
+	if len(stored) > maxPreviewFetchVideos {
+		skipped = len(stored) - maxPreviewFetchVideos
+		stored = stored[:maxPreviewFetchVideos]
+	}
+	yt, err := youtube.NewYoutube(os.Getenv("YOUTUBE_API_KEY"))
+	if err != nil {
+		return nil, err
+	}
+	var vids []string
+	for _, v := range stored {
+		vids = append(vids, v.ID)
+	}
+	videos, err := yt.Videos(vids)
+	if err != nil {
+		return nil, err
+	}
+
+	res := Preview(keyword, videos, names)
+	res.Skipped = skipped
+	return res, nil
+}
+
+// タイトル以外を判定するため、YouTube から動画情報を取得する必要があるか
+func needsYoutube(keyword db.Keyword) bool {
+	for _, scope := range keyword.Scopes {
+		if scope != match.ScopeTitle {
+			return true
+		}
+	}
+	for _, expr := range slices.Concat(keyword.Include, keyword.Ignore) {
+		fs, _ := match.Fields(expr)
+		for _, f := range fs {
+			if f != match.FieldTitle {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 動画ごとにキーワードに一致するか判定する
+func Preview(keyword db.Keyword, videos []yt.Video, names map[string]string) *PreviewResult {
+	res := &PreviewResult{}
+	for _, v := range videos {
+		doc := match.NewDocument(v, names[v.Snippet.ChannelId], keyword.Scopes)
+		if discordnotice.MatchKeyword(keyword, doc) {
+			res.Matched = append(res.Matched, v)
+			continue
+		}
+		if ok, _ := match.MatchAny(keyword.Include, doc); ok {
+			res.Ignored++
+		}
+	}
+	return res
+}
+
+func FormatPreview(res *PreviewResult, days int) string {
+	lines := []string{fmt.Sprintf("直近%d日間で %d件 の動画に一致しました（除外する条件式で除外: %d件）", days, len(res.Matched), res.Ignored)}
+	if res.Skipped != 0 {
+		lines = append(lines, fmt.Sprintf("※ 動画が多いため、新しい%d件のみ判定しました（%d件は判定していません）", maxPreviewFetchVideos, res.Skipped))
+	}
+	for _, v := range res.Matched {
+		lines = append(lines, fmt.Sprintf("- [%s](<https://www.youtube.com/watch?v=%s>)", v.Snippet.Title, v.Id))
+	}
+	return strings.Join(lines, "\n")
+}
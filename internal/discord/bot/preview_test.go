@@ -0,0 +1,64 @@
+package discordbot
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aopontann/niji-tuu/internal/common/db"
+	yt "google.golang.org/api/youtube/v3"
+)
+
+func TestPreview(t *testing.T) {
+	videos := []yt.Video{
+		{Id: "a", Snippet: &yt.VideoSnippet{Title: "【APEX】ランクマッチ", ChannelId: "UC1"}},
+		{Id: "b", Snippet: &yt.VideoSnippet{Title: "【APEX】切り抜き集", ChannelId: "UC2"}},
+		{Id: "c", Snippet: &yt.VideoSnippet{Title: "【雑談】週末", ChannelId: "UC1", Tags: []string{"APEX"}}},
+		{Id: "d", Snippet: &yt.VideoSnippet{Title: "【マイクラ】建築", ChannelId: "UC3"}},
+	}
+	names := map[string]string{"UC1": "葛葉"}
+
+	res := Preview(db.Keyword{Include: []string{"apex"}, Ignore: []string{"切り抜き"}}, videos, names)
+	if len(res.Matched) != 1 || res.Matched[0].Id != "a" || res.Ignored != 1 {
+		t.Errorf("unexpected result %d %d", len(res.Matched), res.Ignored)
+	}
+
+	// 検索範囲にタグを含める場合
+	res = Preview(db.Keyword{Include: []string{"apex"}, Scopes: []string{"title", "tags"}}, videos, names)
+	if len(res.Matched) != 3 || res.Ignored != 0 {
+		t.Errorf("unexpected result %d %d", len(res.Matched), res.Ignored)
+	}
+
+	// vtubers テーブルの名前で検索できる
+	res = Preview(db.Keyword{Include: []string{"channel:葛葉"}}, videos, names)
+	if len(res.Matched) != 2 {
+		t.Errorf("unexpected result %d", len(res.Matched))
+	}
+
+	msg := FormatPreview(res, 7)
+	if !strings.HasPrefix(msg, "直近7日間で 2件") || !strings.Contains(msg, "https://www.youtube.com/watch?v=c") {
+		t.Errorf("unexpected message %s", msg)
+	}
+}
+
+func TestNeedsYoutube(t *testing.T) {
+	cases := []struct {
+		keyword db.Keyword
+		want    bool
+	}{
+		{db.Keyword{Include: []string{"apex"}}, false},
+		{db.Keyword{Include: []string{"title:apex"}, Scopes: []string{"title"}}, false},
+		{db.Keyword{Include: []string{"apex"}, Scopes: []string{"title", "tags"}}, true},
+		{db.Keyword{Include: []string{"apex"}, Ignore: []string{"desc:切り抜き"}}, true},
+		{db.Keyword{Include: []string{"channel:葛葉"}}, true},
+	}
+	for _, c := range cases {
+		if got := needsYoutube(c.keyword); got != c.want {
+			t.Errorf("needsYoutube(%+v) = %v, want %v", c.keyword, got, c.want)
+		}
+	}
+
+	msg := FormatPreview(&PreviewResult{Skipped: 10}, 30)
+	if !strings.Contains(msg, "10件は判定していません") {
+		t.Errorf("unexpected message %s", msg)
+	}
+}
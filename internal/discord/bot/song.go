@@ -0,0 +1,211 @@
+package discordbot
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aopontann/niji-tuu/internal/common/classifier"
+	"github.com/aopontann/niji-tuu/internal/common/db"
+	"github.com/aopontann/niji-tuu/internal/common/youtube"
+	"github.com/aopontann/niji-tuu/internal/common/yturl"
+)
+
+// 通知予定の歌動画と通知時刻の一覧
+func ListSongs() (string, error) {
+	cdb, err := db.NewDB(os.Getenv("DSN"))
+	if err != nil {
+		return "", err
+	}
+	defer cdb.Close()
+
+	reminders, err := cdb.GetUpcomingReminders(os.Getenv("SONG_QUEUE_ID"))
+	if err != nil {
+		return "", err
+	}
+	if len(reminders) == 0 {
+		return "通知予定の歌動画はありません", nil
+	}
+
+	// 動画ごとにまとめる　通知時刻が早い動画から表示する
+	var vids []string
+	byVideo := make(map[string][]db.Reminder)
+	for _, r := range reminders {
+		if _, ok := byVideo[r.VideoID]; !ok {
+			vids = append(vids, r.VideoID)
+		}
+		byVideo[r.VideoID] = append(byVideo[r.VideoID], r)
+	}
+
+	videos, err := cdb.GetVideos(vids)
+	if err != nil {
+		return "", err
+	}
+	titles := make(map[string]string, len(videos))
+	for _, v := range videos {
+		titles[v.ID] = v.Title
+	}
+
+	var lines []string
+	for _, vid := range vids {
+		title := titles[vid]
+		if title == "" {
+			title = vid
+		}
+		lines = append(lines, fmt.Sprintf("- [%s](<https://www.youtube.com/watch?v=%s>)", title, vid))
+		lines = append(lines, "  "+FormatReminders(byVideo[vid]))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// 登録済みの歌みた告知タスクを削除する
+func RemoveSong(url string) (string, error) {
+	vid, err := yturl.VideoID(url)
+	if err != nil {
+		return "", err
+	}
+
+	cdb, err := db.NewDB(os.Getenv("DSN"))
+	if err != nil {
+		return "", err
+	}
+	defer cdb.Close()
+
+	// 記録は表示にのみ使い、記録がなくても取り消す
+	reminders, err := cdb.GetReminders(vid)
+	if err != nil {
+		slog.Warn(err.Error(),
+			slog.String("video_id", vid),
+		)
+	}
+
+	if err := unscheduleSong(vid); err != nil {
+		return "", err
+	}
+	rs := scheduled(reminders, os.Getenv("SONG_QUEUE_ID"))
+	if len(rs) == 0 {
+		return "通知を取り消しました（記録されている通知はありませんでした）", nil
+	}
+	return "通知を取り消しました\n" + FormatReminders(rs), nil
+}
+
+// 動画情報を再取得して、最新の公開予定時刻で歌みた告知タスクを登録し直す
+func RescheduleSong(url string) (string, error) {
+	vid, err := yturl.VideoID(url)
+	if err != nil {
+		return "", err
+	}
+	if err := AddSong(vid); err != nil {
+		return "", err
+	}
+
+	cdb, err := db.NewDB(os.Getenv("DSN"))
+	if err != nil {
+		return "", err
+	}
+	defer cdb.Close()
+
+	reminders, err := cdb.GetReminders(vid)
+	if err != nil {
+		return "", err
+	}
+	return "登録し直しました\n" + FormatReminders(scheduled(reminders, os.Getenv("SONG_QUEUE_ID"))), nil
+}
+
+// 分類器の判定結果と、登録されている通知を表示する
+func SongInfo(url string) (string, error) {
+	vid, err := yturl.VideoID(url)
+	if err != nil {
+		return "", err
+	}
+
+	yt, err := youtube.NewYoutube(os.Getenv("YOUTUBE_API_KEY"))
+	if err != nil {
+		return "", err
+	}
+	videos, err := yt.Videos([]string{vid})
+	if err != nil {
+		return "", err
+	}
+	if len(videos) == 0 {
+		return "", fmt.Errorf("動画が見つかりません")
+	}
+	v := videos[0]
+
+	cdb, err := db.NewDB(os.Getenv("DSN"))
+	if err != nil {
+		return "", err
+	}
+	defer cdb.Close()
+
+	c, err := classifier.NewClassifierFromDB(cdb)
+	if err != nil {
+		return "", err
+	}
+	songThreshold, err := classifier.Threshold("SONG_SCORE_THRESHOLD", classifier.DefaultSongThreshold)
+	if err != nil {
+		return "", err
+	}
+	maybeThreshold, err := classifier.Threshold("MAYBE_SONG_SCORE_THRESHOLD", classifier.DefaultMaybeSongThreshold)
+	if err != nil {
+		return "", err
+	}
+	res := c.Classify(v)
+
+	reminders, err := cdb.GetReminders(vid)
+	if err != nil {
+		return "", err
+	}
+
+	lines := []string{
+		fmt.Sprintf("**%s**", v.Snippet.Title),
+		fmt.Sprintf("判定: %s（スコア %g）", verdict(res.Score, songThreshold, maybeThreshold), res.Score),
+		"根拠: " + formatList(res.Reasons),
+		"通知: " + FormatReminders(reminders),
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func verdict(score float64, songThreshold float64, maybeThreshold float64) string {
+	switch {
+	case score >= songThreshold:
+		return "歌動画"
+	case score >= maybeThreshold:
+		return "判別しづらい動画"
+	}
+	return "歌動画ではない"
+}
+
+// 通知時刻を Discord のタイムスタンプ形式で表示する
+func FormatReminders(reminders []db.Reminder) string {
+	if len(reminders) == 0 {
+		return "なし"
+	}
+	var s []string
+	for _, r := range reminders {
+		status := ""
+		switch {
+		case r.Status == db.ReminderStatusCanceled:
+			status = "（取り消し済み）"
+		case r.Status == db.ReminderStatusDeferred:
+			status = "（保留中）"
+		case r.ScheduleTime.Before(time.Now()):
+			status = "（通知済み）"
+		}
+		s = append(s, fmt.Sprintf("%d分前 <t:%d:f>%s", r.MinutesAgo, r.ScheduleTime.Unix(), status))
+	}
+	return strings.Join(s, ", ")
+}
+
+// 指定したキューの未取り消しの通知　保留中の通知も含む
+func scheduled(reminders []db.Reminder, queueID string) []db.Reminder {
+	var rs []db.Reminder
+	for _, r := range reminders {
+		if r.QueueID == queueID && r.Status != db.ReminderStatusCanceled {
+			rs = append(rs, r)
+		}
+	}
+	return rs
+}
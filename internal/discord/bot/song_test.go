@@ -0,0 +1,45 @@
+package discordbot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aopontann/niji-tuu/internal/common/db"
+)
+
+func TestFormatReminders(t *testing.T) {
+	if s := FormatReminders(nil); s != "なし" {
+		t.Errorf("FormatReminders(nil) = %q", s)
+	}
+
+	future := time.Now().Add(time.Hour)
+	reminders := []db.Reminder{
+		{VideoID: "a", QueueID: "song", MinutesAgo: 60, ScheduleTime: future, Status: db.ReminderStatusScheduled},
+		{VideoID: "a", QueueID: "song", MinutesAgo: 5, ScheduleTime: time.Now().Add(-time.Hour), Status: db.ReminderStatusScheduled},
+		{VideoID: "a", QueueID: "other", MinutesAgo: 5, ScheduleTime: future, Status: db.ReminderStatusCanceled},
+		{VideoID: "a", QueueID: "song", MinutesAgo: 0, ScheduleTime: future.AddDate(0, 2, 0), Status: db.ReminderStatusDeferred},
+	}
+	msg := FormatReminders(reminders)
+	for _, want := range []string{"60分前 <t:", "（通知済み）", "（取り消し済み）", "（保留中）"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("FormatReminders() does not contain %q\n%s", want, msg)
+		}
+	}
+
+	if rs := scheduled(reminders, "song"); len(rs) != 3 {
+		t.Errorf("scheduled() = %d, want 3", len(rs))
+	}
+}
+
+func TestVerdict(t *testing.T) {
+	if v := verdict(1.5, 1, 0); v != "歌動画" {
+		t.Errorf("verdict(1.5) = %q", v)
+	}
+	if v := verdict(0.5, 1, 0); v != "判別しづらい動画" {
+		t.Errorf("verdict(0.5) = %q", v)
+	}
+	if v := verdict(-1, 1, 0); v != "歌動画ではない" {
+		t.Errorf("verdict(-1) = %q", v)
+	}
+}
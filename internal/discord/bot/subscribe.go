@@ -0,0 +1,250 @@
+package discordbot
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/aopontann/niji-tuu/internal/common/db"
+	"github.com/aopontann/niji-tuu/internal/common/task"
+)
+
+const (
+	// 登録、解除するキーワードを選択するメニューの custom_id の接頭辞 例 subscribe:0
+	subscribeCustomIDPrefix = "subscribe:"
+	// 1つのメニューに表示できる選択肢の最大数
+	maxSelectOptions = 25
+	// 1つのメッセージに表示できるメニューの最大数
+	maxActionsRows = 5
+	// メニューに表示する説明の最大文字数
+	maxPlaceholderLength = 150
+)
+
+// パネルを作成したチャンネルにキーワードの一覧を選択するメッセージを送信し、DBに登録する
+func CreateSubscribePanel(channelID string) error {
+	cdb, err := db.NewDB(os.Getenv("DSN"))
+	if err != nil {
+		return err
+	}
+	defer cdb.Close()
+
+	keywords, err := cdb.GetKeywords()
+	if err != nil {
+		return err
+	}
+
+	discord, err := discordgo.New("Bot " + os.Getenv("DISCORD_BOT_TOKEN"))
+	if err != nil {
+		return err
+	}
+	msg, err := discord.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Content:    subscribePanelContent,
+		Components: NewSubscribePanel(keywords),
+	})
+	if err != nil {
+		return err
+	}
+
+	return cdb.SavePanel(db.Panel{MessageID: msg.ID, ChannelID: channelID})
+}
+
+const subscribePanelContent = "通知を受け取りたいキーワードを選択してください\n登録済みのキーワードを選択すると解除します"
+
+// キーワードを25件ずつ選択できるメニューを作成する
+// メニューの数が上限を超える場合は、表示できない分を省略する
+func NewSubscribePanel(keywords []db.Keyword) []discordgo.MessageComponent {
+	var rows []discordgo.MessageComponent
+	minValues := 0
+	for i := 0; i < len(keywords) && len(rows) < maxActionsRows; i += maxSelectOptions {
+		page := keywords[i:min(i+maxSelectOptions, len(keywords))]
+
+		var options []discordgo.SelectMenuOption
+		for _, k := range page {
+			options = append(options, discordgo.SelectMenuOption{
+				Label: k.Name,
+				Value: k.Name,
+			})
+		}
+		rows = append(rows, discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.SelectMenu{
+					CustomID:    fmt.Sprintf("%s%d", subscribeCustomIDPrefix, len(rows)),
+					Placeholder: truncate(fmt.Sprintf("キーワードを選択 (%s 〜 %s)", page[0].Name, page[len(page)-1].Name), maxPlaceholderLength),
+					MinValues:   &minValues,
+					MaxValues:   len(options),
+					Options:     options,
+				},
+			},
+		})
+	}
+	if len(keywords) > maxActionsRows*maxSelectOptions {
+		slog.Warn("パネルに表示できないキーワードがあります",
+			slog.Int("keywords", len(keywords)),
+		)
+	}
+	return rows
+}
+
+// パネルのメニューの custom_id か
+func IsSubscribeCustomID(customID string) bool {
+	return strings.HasPrefix(customID, subscribeCustomIDPrefix)
+}
+
+// 選択したキーワードのロールを、持っていない場合は付与し、持っている場合は外す
+// 付与、解除したキーワード名を返す
+func ToggleKeywordRoles(guildID string, member *discordgo.Member, names []string) (added []string, removed []string, err error) {
+	if member == nil || member.User == nil {
+		return nil, nil, fmt.Errorf("サーバー内で操作してください")
+	}
+
+	cdb, err := db.NewDB(os.Getenv("DSN"))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cdb.Close()
+
+	keywords, err := cdb.GetKeywords()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	discord, err := discordgo.New("Bot " + os.Getenv("DISCORD_BOT_TOKEN"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, k := range keywords {
+		if !slices.Contains(names, k.Name) {
+			continue
+		}
+		if slices.Contains(member.Roles, k.RoleID) {
+			if err := discord.GuildMemberRoleRemove(guildID, member.User.ID, k.RoleID); err != nil {
+				return added, removed, err
+			}
+			removed = append(removed, k.Name)
+		} else {
+			if err := discord.GuildMemberRoleAdd(guildID, member.User.ID, k.RoleID); err != nil {
+				return added, removed, err
+			}
+			added = append(added, k.Name)
+		}
+	}
+	return added, removed, nil
+}
+
+// 登録されている全てのパネルを最新のキーワードの一覧に更新する
+// 削除されたメッセージのパネルはDBからも削除する
+func RefreshSubscribePanels() error {
+	cdb, err := db.NewDB(os.Getenv("DSN"))
+	if err != nil {
+		return err
+	}
+	defer cdb.Close()
+
+	panels, err := cdb.GetPanels()
+	if err != nil {
+		return err
+	}
+	if len(panels) == 0 {
+		return nil
+	}
+
+	keywords, err := cdb.GetKeywords()
+	if err != nil {
+		return err
+	}
+	components := NewSubscribePanel(keywords)
+	content := subscribePanelContent
+
+	discord, err := discordgo.New("Bot " + os.Getenv("DISCORD_BOT_TOKEN"))
+	if err != nil {
+		return err
+	}
+	for _, p := range panels {
+		_, err := discord.ChannelMessageEditComplex(&discordgo.MessageEdit{
+			ID:         p.MessageID,
+			Channel:    p.ChannelID,
+			Content:    &content,
+			Components: &components,
+		})
+		var restErr *discordgo.RESTError
+		if errors.As(err, &restErr) && restErr.Response != nil && restErr.Response.StatusCode == http.StatusNotFound {
+			slog.Warn("パネルのメッセージが削除されています",
+				slog.String("message_id", p.MessageID),
+			)
+			if err := cdb.DeletePanel(p.MessageID); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// パネルの更新を依頼する
+// テストで差し替えられるように変数にする
+var enqueuePanelRefresh = enqueuePanelRefreshTask
+
+// キーワードの変更後にパネルの更新を依頼する　失敗してもキーワードの変更は成功しているため、ログのみ出す
+// パネルが多いと時間がかかるため、コマンドの処理とは別に RefreshPanelsHandler で更新する
+func refreshSubscribePanels() {
+	if err := enqueuePanelRefresh(); err != nil {
+		slog.Error(err.Error())
+	}
+}
+
+// Cloud Tasks で RefreshPanelsHandler を呼び出す
+// 環境変数 SCHEDULER が local の場合は、リクエスト後も処理を続けられるため goroutine で更新する
+func enqueuePanelRefreshTask() error {
+	if os.Getenv("SCHEDULER") == "local" {
+		go func() {
+			if err := RefreshSubscribePanels(); err != nil {
+				slog.Error(err.Error())
+			}
+		}()
+		return nil
+	}
+
+	ctask, err := task.NewTask(nil)
+	if err != nil {
+		return err
+	}
+	defer ctask.Close()
+	return ctask.CreateHTTPTask(os.Getenv("DISCORD_INTERACTION_QUEUE_ID"), os.Getenv("DISCORD_PANEL_REFRESH_URL"), nil, nil)
+}
+
+// 登録されている全てのパネルを更新する
+// 何度実行しても結果は変わらないため、失敗した場合は Cloud Tasks にリトライさせる
+func RefreshPanelsHandler(w http.ResponseWriter, r *http.Request) {
+	if err := RefreshSubscribePanels(); err != nil {
+		slog.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// ロールを付与、解除した結果のメッセージ　途中で失敗した場合はそれまでの結果とエラーを表示する
+func toggleResultMessage(added []string, removed []string, err error) string {
+	var lines []string
+	if len(added) != 0 {
+		lines = append(lines, "登録しました: "+strings.Join(added, ", "))
+	}
+	if len(removed) != 0 {
+		lines = append(lines, "解除しました: "+strings.Join(removed, ", "))
+	}
+	if err != nil {
+		lines = append(lines, "失敗しました："+err.Error())
+	}
+	if len(lines) == 0 {
+		return "変更はありません"
+	}
+	return strings.Join(lines, "\n")
+}
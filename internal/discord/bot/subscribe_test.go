@@ -0,0 +1,59 @@
+package discordbot
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/aopontann/niji-tuu/internal/common/db"
+)
+
+func TestNewSubscribePanel(t *testing.T) {
+	var keywords []db.Keyword
+	for i := 0; i < 60; i++ {
+		keywords = append(keywords, db.Keyword{Name: fmt.Sprintf("keyword%02d", i), RoleID: fmt.Sprint(i)})
+	}
+
+	rows := NewSubscribePanel(keywords)
+	if len(rows) != 3 {
+		t.Fatalf("len(rows) = %d, want 3", len(rows))
+	}
+	menu := rows[2].(discordgo.ActionsRow).Components[0].(discordgo.SelectMenu)
+	if menu.CustomID != "subscribe:2" || len(menu.Options) != 10 || menu.MaxValues != 10 {
+		t.Errorf("unexpected menu %s %d %d", menu.CustomID, len(menu.Options), menu.MaxValues)
+	}
+	if menu.Options[0].Value != "keyword50" || *menu.MinValues != 0 {
+		t.Errorf("unexpected options %+v", menu.Options[0])
+	}
+	if !IsSubscribeCustomID(menu.CustomID) || IsSubscribeCustomID("song-review:song:abc") {
+		t.Error("IsSubscribeCustomID returned unexpected result")
+	}
+
+	// メニューは5つまで
+	for i := 60; i < 200; i++ {
+		keywords = append(keywords, db.Keyword{Name: fmt.Sprintf("keyword%03d", i)})
+	}
+	if rows := NewSubscribePanel(keywords); len(rows) != 5 {
+		t.Errorf("len(rows) = %d, want 5", len(rows))
+	}
+}
+
+func TestToggleResultMessage(t *testing.T) {
+	cases := []struct {
+		added   []string
+		removed []string
+		err     error
+		want    string
+	}{
+		{nil, nil, nil, "変更はありません"},
+		{[]string{"apex", "valorant"}, nil, nil, "登録しました: apex, valorant"},
+		{[]string{"apex"}, []string{"minecraft"}, errors.New("forbidden"), "登録しました: apex\n解除しました: minecraft\n失敗しました：forbidden"},
+	}
+	for _, c := range cases {
+		if got := toggleResultMessage(c.added, c.removed, c.err); got != c.want {
+			t.Errorf("toggleResultMessage() = %q, want %q", got, c.want)
+		}
+	}
+}
@@ -1,11 +1,17 @@
 package discordcli
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"reflect"
+	"slices"
+	"strings"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/joho/godotenv"
+
+	discordbot "github.com/aopontann/niji-tuu/internal/discord/bot"
 )
 
 func ListCommand() {
@@ -27,6 +33,7 @@ func ListCommand() {
 	}
 }
 
+// 登録されているコマンドとの差分を表示してから、discordbot のコマンドで上書きする
 func BulkCommand() {
 	godotenv.Load(".env.prod")
 	guildID := os.Getenv("DISCORD_GUILD_ID")
@@ -37,53 +44,162 @@ func BulkCommand() {
 		panic(err)
 	}
 
-	_, err = discord.ApplicationCommandBulkOverwrite(appID, guildID, []*discordgo.ApplicationCommand{
-		{
-			Name:        "song",
-			Description: "歌みた動画の管理",
-			Options: []*discordgo.ApplicationCommandOption{
-				{
-					Name:        "add",
-					Description: "歌みた動画の追加",
-					Type:        discordgo.ApplicationCommandOptionSubCommand,
-					Options: []*discordgo.ApplicationCommandOption{
-						{
-							Name:        "url",
-							Description: "動画のURL",
-							Type:        discordgo.ApplicationCommandOptionString,
-							Required:    true,
-						},
-					},
-				},
-			},
-		},
-		{
-			Name:        "keyword",
-			Description: "キーワードの管理",
-			Options: []*discordgo.ApplicationCommandOption{
-				{
-					Name:        "add",
-					Description: "キーワードを登録する",
-					Type:        discordgo.ApplicationCommandOptionSubCommand,
-					Options: []*discordgo.ApplicationCommandOption{
-						{
-							Name:        "keyword",
-							Description: "登録するキーワード",
-							Type:        discordgo.ApplicationCommandOptionString,
-							Required:    true,
-						},
-						{
-							Name:        "category_id",
-							Description: "追加先のカテゴリID",
-							Type:        discordgo.ApplicationCommandOptionString,
-							Required:    true,
-						},
-					},
-				},
-			},
-		},
-	})
+	current, err := discord.ApplicationCommands(appID, guildID)
+	if err != nil {
+		panic(err)
+	}
+	cmds := discordbot.ApplicationCommands()
+	diff := DiffCommands(current, cmds)
+	if len(diff) == 0 {
+		fmt.Println("変更はありません")
+		return
+	}
+	printDiff(diff)
+
+	_, err = discord.ApplicationCommandBulkOverwrite(appID, guildID, cmds)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// 登録されているコマンドと discordbot のコマンドの差分を表示する　上書きはしない
+func DiffCommand() {
+	godotenv.Load(".env.prod")
+	guildID := os.Getenv("DISCORD_GUILD_ID")
+	appID := os.Getenv("DISCORD_APP_ID")
+
+	discord, err := discordgo.New("Bot " + os.Getenv("DISCORD_BOT_TOKEN"))
+	if err != nil {
+		panic(err)
+	}
+
+	current, err := discord.ApplicationCommands(appID, guildID)
 	if err != nil {
 		panic(err)
 	}
+	diff := DiffCommands(current, discordbot.ApplicationCommands())
+	if len(diff) == 0 {
+		fmt.Println("変更はありません")
+		return
+	}
+	printDiff(diff)
+}
+
+func printDiff(diff []string) {
+	for _, d := range diff {
+		fmt.Println(d)
+	}
+}
+
+// コマンドの差分を「+ 追加」「- 削除」「~ 変更」の形式で返す
+// 変更されたコマンドは、続けて変更されたオプションのパスと項目を字下げして返す
+//
+//	~ keyword
+//	    + keyword include add-channel
+//	    ~ keyword preview days: description, max_value
+func DiffCommands(current []*discordgo.ApplicationCommand, next []*discordgo.ApplicationCommand) []string {
+	var diff []string
+	for _, n := range next {
+		i := slices.IndexFunc(current, func(c *discordgo.ApplicationCommand) bool { return c.Name == n.Name })
+		if i < 0 {
+			diff = append(diff, "+ "+n.Name)
+			continue
+		}
+		if commandJSON(current[i]) == commandJSON(n) {
+			continue
+		}
+		diff = append(diff, "~ "+n.Name)
+		var details []string
+		if fields := changedFields(commandJSON(withoutOptions(current[i])), commandJSON(withoutOptions(n))); len(fields) != 0 {
+			details = append(details, fmt.Sprintf("~ %s: %s", n.Name, strings.Join(fields, ", ")))
+		}
+		details = append(details, diffOptions(n.Name, current[i].Options, n.Options)...)
+		for _, d := range details {
+			diff = append(diff, "    "+d)
+		}
+	}
+	for _, c := range current {
+		if !slices.ContainsFunc(next, func(n *discordgo.ApplicationCommand) bool { return n.Name == c.Name }) {
+			diff = append(diff, "- "+c.Name)
+		}
+	}
+	return diff
+}
+
+// オプションの差分を、コマンド名から続くパスで返す
+func diffOptions(path string, current []*discordgo.ApplicationCommandOption, next []*discordgo.ApplicationCommandOption) []string {
+	var diff []string
+	for _, n := range next {
+		i := slices.IndexFunc(current, func(c *discordgo.ApplicationCommandOption) bool { return c.Name == n.Name })
+		if i < 0 {
+			diff = append(diff, "+ "+path+" "+n.Name)
+			continue
+		}
+		c := current[i]
+		if fields := changedFields(optionJSON(c, false), optionJSON(n, false)); len(fields) != 0 {
+			diff = append(diff, fmt.Sprintf("~ %s %s: %s", path, n.Name, strings.Join(fields, ", ")))
+		}
+		diff = append(diff, diffOptions(path+" "+n.Name, c.Options, n.Options)...)
+	}
+	for _, c := range current {
+		if !slices.ContainsFunc(next, func(n *discordgo.ApplicationCommandOption) bool { return n.Name == c.Name }) {
+			diff = append(diff, "- "+path+" "+c.Name)
+		}
+	}
+
+	// 追加、削除、変更がなくても、並び順が変わるとDiscordでの表示順が変わる
+	if len(diff) == 0 && !slices.EqualFunc(current, next, func(c, n *discordgo.ApplicationCommandOption) bool {
+		return optionJSON(c, true) == optionJSON(n, true)
+	}) {
+		diff = append(diff, "~ "+path+": オプションの順番")
+	}
+	return diff
+}
+
+func withoutOptions(c *discordgo.ApplicationCommand) *discordgo.ApplicationCommand {
+	copied := *c
+	copied.Options = nil
+	return &copied
+}
+
+// オプションをJSONに変換する　nested が false の場合は子のオプションを含めない
+func optionJSON(o *discordgo.ApplicationCommandOption, nested bool) string {
+	copied := *o
+	if !nested {
+		copied.Options = nil
+	}
+	b, _ := json.Marshal(&copied)
+	return string(b)
+}
+
+// JSONのオブジェクトで値が異なる項目の名前を返す
+func changedFields(current string, next string) []string {
+	var c, n map[string]any
+	json.Unmarshal([]byte(current), &c)
+	json.Unmarshal([]byte(next), &n)
+
+	var fields []string
+	for k, v := range n {
+		if !reflect.DeepEqual(c[k], v) {
+			fields = append(fields, k)
+		}
+	}
+	for k := range c {
+		if _, ok := n[k]; !ok {
+			fields = append(fields, k)
+		}
+	}
+	slices.Sort(fields)
+	return fields
+}
+
+// Discord が付与するIDなどを除いて、比較できるようにJSONに変換する
+func commandJSON(c *discordgo.ApplicationCommand) string {
+	b, _ := json.Marshal(&discordgo.ApplicationCommand{
+		Name:                     c.Name,
+		Description:              c.Description,
+		Options:                  c.Options,
+		DefaultMemberPermissions: c.DefaultMemberPermissions,
+	})
+	return string(b)
 }
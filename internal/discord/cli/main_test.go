@@ -0,0 +1,74 @@
+package discordcli
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestDiffCommands(t *testing.T) {
+	current := []*discordgo.ApplicationCommand{
+		{ID: "1", ApplicationID: "app", Version: "1", Name: "song", Description: "歌みた動画の管理"},
+		{ID: "2", ApplicationID: "app", Version: "1", Name: "keyword", Description: "キーワードの管理"},
+		{ID: "3", ApplicationID: "app", Version: "1", Name: "old", Description: "削除するコマンド"},
+	}
+	next := []*discordgo.ApplicationCommand{
+		{Name: "song", Description: "歌みた動画の管理"},
+		{Name: "keyword", Description: "キーワードの管理", Options: []*discordgo.ApplicationCommandOption{
+			{Name: "list", Description: "一覧", Type: discordgo.ApplicationCommandOptionSubCommand},
+		}},
+		{Name: "subscribe", Description: "パネルを作成する"},
+	}
+
+	diff := DiffCommands(current, next)
+	want := []string{"~ keyword", "    + keyword list", "+ subscribe", "- old"}
+	if !slices.Equal(diff, want) {
+		t.Errorf("DiffCommands() = %v, want %v", diff, want)
+	}
+
+	if diff := DiffCommands(next, next); len(diff) != 0 {
+		t.Errorf("DiffCommands() = %v, want no diff", diff)
+	}
+}
+
+func TestDiffCommandsOptions(t *testing.T) {
+	days := func(max float64) *discordgo.ApplicationCommandOption {
+		return &discordgo.ApplicationCommandOption{Name: "days", Description: "期間", Type: discordgo.ApplicationCommandOptionInteger, MaxValue: max}
+	}
+	sub := func(name string, options ...*discordgo.ApplicationCommandOption) *discordgo.ApplicationCommandOption {
+		return &discordgo.ApplicationCommandOption{Name: name, Description: name, Type: discordgo.ApplicationCommandOptionSubCommand, Options: options}
+	}
+	permissions := int64(discordgo.PermissionManageServer)
+
+	current := []*discordgo.ApplicationCommand{
+		{Name: "keyword", Description: "キーワードの管理", Options: []*discordgo.ApplicationCommandOption{
+			sub("preview", days(30)),
+			sub("list"),
+			sub("old"),
+		}},
+		{Name: "song", Description: "歌みた動画の管理", Options: []*discordgo.ApplicationCommandOption{sub("add"), sub("list")}},
+	}
+	next := []*discordgo.ApplicationCommand{
+		{Name: "keyword", Description: "キーワードの管理", DefaultMemberPermissions: &permissions, Options: []*discordgo.ApplicationCommandOption{
+			sub("preview", days(60)),
+			sub("list"),
+			sub("show"),
+		}},
+		{Name: "song", Description: "歌みた動画の管理", Options: []*discordgo.ApplicationCommandOption{sub("list"), sub("add")}},
+	}
+
+	diff := DiffCommands(current, next)
+	want := []string{
+		"~ keyword",
+		"    ~ keyword: default_member_permissions",
+		"    ~ keyword preview days: max_value",
+		"    + keyword show",
+		"    - keyword old",
+		"~ song",
+		"    ~ song: オプションの順番",
+	}
+	if !slices.Equal(diff, want) {
+		t.Errorf("DiffCommands() = %q, want %q", diff, want)
+	}
+}
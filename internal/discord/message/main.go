@@ -0,0 +1,254 @@
+package discordmessage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aopontann/niji-tuu/internal/common/notifier"
+	"github.com/aopontann/niji-tuu/internal/common/youtube"
+	"github.com/bwmarrin/discordgo"
+	yt "google.golang.org/api/youtube/v3"
+)
+
+// 埋め込みの色
+const (
+	colorLive     = 0xff0000
+	colorPremiere = 0x3ea6ff
+	colorVideo    = 0x909090
+	colorEnded    = 0x2f3136
+)
+
+// 告知した動画の状態
+const (
+	StatusUpcoming = "upcoming"
+	StatusLive     = "live"
+	StatusEnded    = "ended"
+	StatusDeleted  = "deleted"
+)
+
+// 歌動画か判定するボタンの custom_id の接頭辞 例 song-review:song:動画ID
+const reviewCustomIDPrefix = "song-review"
+
+// 判定ボタンの種類
+const (
+	ReviewSong    = "song"
+	ReviewNotSong = "not-song"
+)
+
+// ロールをメンションして、動画情報の埋め込みを付けた告知メッセージを作成する
+// roleID が空の場合はメンションしない
+func NewAnnounceMessage(roleID string, video yt.Video, channelName string) *discordgo.MessageSend {
+	msg := &discordgo.MessageSend{
+		Embeds:          []*discordgo.MessageEmbed{NewVideoEmbed(video, channelName)},
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	}
+	if roleID != "" {
+		msg.Content = fmt.Sprintf("<@&%s>", roleID)
+		msg.AllowedMentions.Roles = []string{roleID}
+	}
+	return msg
+}
+
+// 以下は notifier の DiscordMessageBuilder として渡す
+
+// ロールをメンションした告知メッセージ
+func AnnounceMessage(event *notifier.VideoEvent) *discordgo.MessageSend {
+	return NewAnnounceMessage(event.RoleID, event.Video, event.ChannelName)
+}
+
+// 歌動画か判定するボタンを付けたメッセージ
+func ReviewMessage(event *notifier.VideoEvent) *discordgo.MessageSend {
+	return NewReviewMessage(event.Message, event.Video, event.ChannelName)
+}
+
+// 通知の見出しと動画情報の埋め込みのみのメッセージ　Webhook で送信する
+func WebhookMessage(event *notifier.VideoEvent) *discordgo.MessageSend {
+	return &discordgo.MessageSend{
+		Content: event.Message,
+		Embeds:  []*discordgo.MessageEmbed{NewVideoEmbed(event.Video, event.ChannelName)},
+	}
+}
+
+// 歌動画か判別しづらい動画を、歌動画か判定するボタンを付けて送信するメッセージを作成する
+func NewReviewMessage(content string, video yt.Video, channelName string) *discordgo.MessageSend {
+	return &discordgo.MessageSend{
+		Content: content,
+		Embeds:  []*discordgo.MessageEmbed{NewVideoEmbed(video, channelName)},
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{
+						Label:    "歌動画",
+						Style:    discordgo.SuccessButton,
+						CustomID: ReviewCustomID(ReviewSong, video.Id),
+					},
+					discordgo.Button{
+						Label:    "歌動画ではない",
+						Style:    discordgo.SecondaryButton,
+						CustomID: ReviewCustomID(ReviewNotSong, video.Id),
+					},
+				},
+			},
+		},
+	}
+}
+
+func ReviewCustomID(decision string, vid string) string {
+	return strings.Join([]string{reviewCustomIDPrefix, decision, vid}, ":")
+}
+
+// 判定ボタンの custom_id から判定と動画IDを取り出す
+// 判定ボタン以外の custom_id の場合は ok に false を返す
+func ParseReviewCustomID(customID string) (decision string, vid string, ok bool) {
+	s := strings.SplitN(customID, ":", 3)
+	if len(s) != 3 || s[0] != reviewCustomIDPrefix || s[2] == "" {
+		return "", "", false
+	}
+	if s[1] != ReviewSong && s[1] != ReviewNotSong {
+		return "", "", false
+	}
+	return s[1], s[2], true
+}
+
+// 動画のタイトル、チャンネル名、サムネイル、公開予定時刻、動画時間、配信の種類を表示する埋め込みを作成する
+// channelName が空の場合は YouTube のチャンネル名を表示する
+// 一部の項目のみ取得した動画でも、取得していない項目を省略して作成する
+func NewVideoEmbed(video yt.Video, channelName string) *discordgo.MessageEmbed {
+	snippet := video.Snippet
+	if snippet == nil {
+		snippet = &yt.VideoSnippet{}
+	}
+	if channelName == "" {
+		channelName = snippet.ChannelTitle
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title: snippet.Title,
+		URL:   "https://www.youtube.com/watch?v=" + video.Id,
+		Color: colorVideo,
+	}
+	if channelName != "" || snippet.ChannelId != "" {
+		embed.Author = &discordgo.MessageEmbedAuthor{
+			Name: channelName,
+			URL:  "https://www.youtube.com/channel/" + snippet.ChannelId,
+		}
+	}
+
+	if snippet.Thumbnails != nil && snippet.Thumbnails.High != nil {
+		embed.Image = &discordgo.MessageEmbedImage{URL: snippet.Thumbnails.High.Url}
+	}
+
+	badge := "🎞️ 動画"
+	switch {
+	case IsLiveStream(video):
+		badge = "🔴 生放送"
+		embed.Color = colorLive
+	case video.LiveStreamingDetails != nil:
+		badge = "🎬 プレミア公開"
+		embed.Color = colorPremiere
+	}
+	embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: "種類", Value: badge, Inline: true})
+
+	if video.LiveStreamingDetails != nil {
+		if t, err := time.Parse(time.RFC3339, video.LiveStreamingDetails.ScheduledStartTime); err == nil {
+			embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+				Name:   "公開予定",
+				Value:  fmt.Sprintf("<t:%d:f>（<t:%d:R>）", t.Unix(), t.Unix()),
+				Inline: true,
+			})
+		}
+	}
+
+	// 生放送の場合、配信が終わるまで動画時間は P0D になる
+	if video.ContentDetails != nil && !IsLiveStream(video) {
+		if d := FormatDuration(video.ContentDetails.Duration); d != "" {
+			embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: "長さ", Value: d, Inline: true})
+		}
+	}
+
+	return embed
+}
+
+// 生放送の動画か　プレミア公開の場合は動画時間が設定されている
+// 動画時間を取得していない場合は生放送ではないとみなす
+func IsLiveStream(video yt.Video) bool {
+	return video.LiveStreamingDetails != nil && video.ContentDetails != nil && video.ContentDetails.Duration == "P0D"
+}
+
+// ISO 8601 形式の動画時間を 1:02:03 の形式に変換する
+// 変換できない場合、または0秒の場合は空文字を返す
+func FormatDuration(iso string) string {
+	d, err := youtube.ParseDuration(iso)
+	if err != nil || d == 0 {
+		return ""
+	}
+
+	h := int(d.Hours())
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, int(d.Minutes())%60, int(d.Seconds())%60)
+	}
+	return fmt.Sprintf("%d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+}
+
+// 再取得した動画情報から、告知した動画の状態を判定する
+func VideoStatus(video yt.Video) string {
+	switch video.Snippet.LiveBroadcastContent {
+	case "upcoming":
+		return StatusUpcoming
+	case "live":
+		return StatusLive
+	}
+	return StatusEnded
+}
+
+// 配信中、配信終了の状態を表示する埋め込みを作成する
+func NewStatusEmbed(video yt.Video, channelName string, status string) *discordgo.MessageEmbed {
+	embed := NewVideoEmbed(video, channelName)
+
+	switch status {
+	case StatusLive:
+		embed.Description = "🔴 **LIVE now**"
+		if d := video.LiveStreamingDetails; d != nil && d.ActualStartTime != "" {
+			if t, err := time.Parse(time.RFC3339, d.ActualStartTime); err == nil {
+				embed.Description += fmt.Sprintf("（<t:%d:R>に開始）", t.Unix())
+			}
+		}
+	case StatusEnded:
+		embed.Description = "⏹️ 配信は終了しました"
+		embed.Color = colorEnded
+		// 生放送の場合は動画時間が設定されるまで時間がかかるため、実際の開始、終了時刻から計算する
+		if d := endedDuration(video); d != "" {
+			embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: "配信時間", Value: d, Inline: true})
+		}
+	}
+
+	return embed
+}
+
+// 動画情報を取得できなくなった動画の埋め込みを作成する
+func NewDeletedEmbed(vid string, title string) *discordgo.MessageEmbed {
+	return &discordgo.MessageEmbed{
+		Title:       title,
+		URL:         "https://www.youtube.com/watch?v=" + vid,
+		Description: "❌ 配信はキャンセル、または削除されました",
+		Color:       colorEnded,
+	}
+}
+
+// 実際の開始時刻と終了時刻から配信時間を計算する
+func endedDuration(video yt.Video) string {
+	d := video.LiveStreamingDetails
+	if d == nil || d.ActualStartTime == "" || d.ActualEndTime == "" {
+		return ""
+	}
+	start, err := time.Parse(time.RFC3339, d.ActualStartTime)
+	if err != nil {
+		return ""
+	}
+	end, err := time.Parse(time.RFC3339, d.ActualEndTime)
+	if err != nil {
+		return ""
+	}
+	return FormatDuration(fmt.Sprintf("PT%dS", int(end.Sub(start).Seconds())))
+}
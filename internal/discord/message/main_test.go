@@ -0,0 +1,144 @@
+package discordmessage
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"google.golang.org/api/youtube/v3"
+)
+
+func TestFormatDuration(t *testing.T) {
+	cases := map[string]string{
+		"PT4M13S":   "4:13",
+		"PT1H2M3S":  "1:02:03",
+		"P1DT2H":    "26:00:00",
+		"PT45S":     "0:45",
+		"P0D":       "",
+		"invalid":   "",
+		"PT10M":     "10:00",
+		"PT1H0M5S":  "1:00:05",
+		"PT100M10S": "1:40:10",
+	}
+	for iso, want := range cases {
+		if got := FormatDuration(iso); got != want {
+			t.Errorf("FormatDuration(%q) = %q, want %q", iso, got, want)
+		}
+	}
+}
+
+func TestNewAnnounceMessage(t *testing.T) {
+	video := youtube.Video{
+		Id: "EgaXyUcsM48",
+		Snippet: &youtube.VideoSnippet{
+			Title:        "【歌ってみた】テスト",
+			ChannelId:    "UC0g1AE0DOjBYnLhkgoRWN1w",
+			ChannelTitle: "YouTubeのチャンネル名",
+		},
+		ContentDetails:       &youtube.VideoContentDetails{Duration: "PT4M13S"},
+		LiveStreamingDetails: &youtube.VideoLiveStreamingDetails{ScheduledStartTime: "2025-04-01T12:00:00Z"},
+	}
+
+	msg := NewAnnounceMessage("1234", video, "ライバー名")
+	if msg.Content != "<@&1234>" {
+		t.Errorf("unexpected content %q", msg.Content)
+	}
+	if len(msg.AllowedMentions.Roles) != 1 || msg.AllowedMentions.Roles[0] != "1234" {
+		t.Errorf("expected role 1234 to be mentioned, got %v", msg.AllowedMentions.Roles)
+	}
+
+	embed := msg.Embeds[0]
+	if embed.Author.Name != "ライバー名" {
+		t.Errorf("unexpected author %q", embed.Author.Name)
+	}
+
+	var values []string
+	for _, f := range embed.Fields {
+		values = append(values, f.Value)
+	}
+	joined := strings.Join(values, " ")
+	for _, want := range []string{"プレミア公開", "<t:1743508800:R>", "4:13"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("expected fields to contain %q, got %q", want, joined)
+		}
+	}
+}
+
+// 一部の項目のみ取得した動画でも作成できる
+func TestNewVideoEmbedPartialVideo(t *testing.T) {
+	video := youtube.Video{
+		Id:                   "EgaXyUcsM48",
+		Snippet:              &youtube.VideoSnippet{Title: "【歌ってみた】テスト"},
+		LiveStreamingDetails: &youtube.VideoLiveStreamingDetails{},
+	}
+	if IsLiveStream(video) {
+		t.Error("IsLiveStream() = true without content details")
+	}
+	embed := NewVideoEmbed(video, "ライバー名")
+	if embed.Title != video.Snippet.Title || embed.Author.Name != "ライバー名" {
+		t.Errorf("unexpected embed %+v", embed)
+	}
+	for _, f := range embed.Fields {
+		if f.Name == "長さ" {
+			t.Errorf("unexpected duration field %q", f.Value)
+		}
+	}
+
+	if embed := NewVideoEmbed(youtube.Video{Id: "EgaXyUcsM48"}, ""); embed.URL != "https://www.youtube.com/watch?v=EgaXyUcsM48" || embed.Author != nil {
+		t.Errorf("unexpected embed %+v", embed)
+	}
+}
+
+func TestNewStatusEmbed(t *testing.T) {
+	video := youtube.Video{
+		Id:             "EgaXyUcsM48",
+		Snippet:        &youtube.VideoSnippet{Title: "雑談", LiveBroadcastContent: "none"},
+		ContentDetails: &youtube.VideoContentDetails{Duration: "P0D"},
+		LiveStreamingDetails: &youtube.VideoLiveStreamingDetails{
+			ScheduledStartTime: "2025-04-01T12:00:00Z",
+			ActualStartTime:    "2025-04-01T12:03:00Z",
+			ActualEndTime:      "2025-04-01T14:05:30Z",
+		},
+	}
+
+	status := VideoStatus(video)
+	if status != StatusEnded {
+		t.Fatalf("expected %s, got %s", StatusEnded, status)
+	}
+
+	embed := NewStatusEmbed(video, "ライバー名", status)
+	last := embed.Fields[len(embed.Fields)-1]
+	if last.Name != "配信時間" || last.Value != "2:02:30" {
+		t.Errorf("unexpected duration field %s: %s", last.Name, last.Value)
+	}
+}
+
+func TestReviewCustomID(t *testing.T) {
+	decision, vid, ok := ParseReviewCustomID(ReviewCustomID(ReviewNotSong, "a-b:c_d"))
+	if !ok || decision != ReviewNotSong || vid != "a-b:c_d" {
+		t.Errorf("ParseReviewCustomID() = %q %q %v", decision, vid, ok)
+	}
+
+	for _, id := range []string{"", "song-review:song:", "song-review:maybe:abc", "other:song:abc"} {
+		if _, _, ok := ParseReviewCustomID(id); ok {
+			t.Errorf("ParseReviewCustomID(%q) expected not ok", id)
+		}
+	}
+}
+
+func TestNewReviewMessage(t *testing.T) {
+	v := youtube.Video{
+		Id:             "abc",
+		Snippet:        &youtube.VideoSnippet{Title: "ヒバナ", ChannelTitle: "Kuzuha Channel"},
+		ContentDetails: &youtube.VideoContentDetails{Duration: "PT4M"},
+	}
+	msg := NewReviewMessage("歌動画か判定してください", v, "")
+
+	row, ok := msg.Components[0].(discordgo.ActionsRow)
+	if !ok || len(row.Components) != 2 {
+		t.Fatalf("unexpected components %#v", msg.Components)
+	}
+	if b := row.Components[0].(discordgo.Button); b.CustomID != "song-review:song:abc" {
+		t.Errorf("unexpected custom_id %q", b.CustomID)
+	}
+}
@@ -6,12 +6,17 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
-	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/aopontann/niji-tuu/internal/common/db"
+	"github.com/aopontann/niji-tuu/internal/common/match"
+	"github.com/aopontann/niji-tuu/internal/common/notifier"
 	"github.com/aopontann/niji-tuu/internal/common/youtube"
+	"github.com/aopontann/niji-tuu/internal/common/yturl"
+	discordmessage "github.com/aopontann/niji-tuu/internal/discord/message"
 	"github.com/bwmarrin/discordgo"
+	multierror "github.com/hashicorp/go-multierror"
 )
 
 func Handler(w http.ResponseWriter, r *http.Request) {
@@ -22,15 +27,21 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	vid := r.FormValue("v")
-	if vid == "" {
+	v := r.FormValue("v")
+	if v == "" {
 		msg := "クエリパラメータ v が指定されていません"
 		slog.Error(msg)
 		http.Error(w, msg, http.StatusBadRequest)
 		return
 	}
+	vid, err := yturl.VideoID(v)
+	if err != nil {
+		slog.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	err := DiscordAnnounceJob(vid)
+	err = DiscordAnnounceJob(vid)
 	if err != nil {
 		slog.Error(err.Error())
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -66,11 +77,23 @@ func DiscordAnnounceJob(vid string) error {
 
 	title := videos[0].Snippet.Title
 
+	// 取得できない場合は YouTube のチャンネル名を表示する
+	channelName, err := cdb.GetVtuberName(videos[0].Snippet.ChannelId)
+	if err != nil {
+		slog.Warn(err.Error())
+	}
+
 	slog.Info("discord-announce",
 		slog.String("video_id", vid),
 		slog.String("title", title),
 	)
 
+	cid := videos[0].Snippet.ChannelId
+	groups, err := cdb.GetVtuberGroups(cid)
+	if err != nil {
+		return err
+	}
+
 	keywords, err := cdb.GetKeywords()
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -80,35 +103,135 @@ func DiscordAnnounceJob(vid string) error {
 		return err
 	}
 
+	// Discord への告知のエラーと、キーワードごとに設定された通知先へのエラー
+	var merr, targetErr *multierror.Error
 	for _, keyword := range keywords {
-		// 小文字に統一してから一致チェック
-		titleLower := strings.ToLower(title)
-
-		// キーワードに一致するか
-		words := strings.Join(keyword.Include, "|")
-		wordsLower := strings.ToLower(words)
-		regPattern := ".*" + wordsLower + ".*"
-		regex, _ := regexp.Compile(regPattern)
-		if !regex.MatchString(titleLower) {
+		if !MatchChannel(keyword, cid, groups) {
 			continue
 		}
 
-		// 除外するキーワードに一致した場合通知しない
-		words = strings.Join(keyword.Ignore, "|")
-		wordsLower = strings.ToLower(words)
-		regPattern = ".*" + wordsLower + ".*"
-		regex, _ = regexp.Compile(regPattern)
-		if len(keyword.Ignore) != 0 && regex.MatchString(titleLower) {
+		// キーワードごとに検索範囲が異なるため、キーワードごとに作成する
+		doc := match.NewDocument(videos[0], channelName, keyword.Scopes)
+		if !MatchKeyword(keyword, doc) {
+			continue
+		}
+
+		// Cloud Tasks のリトライで二重に送信しないように、送信済みかチェック
+		sent, err := cdb.NotificationSent(vid, keyword.ChannelID, db.NotificationKindKeyword)
+		if err != nil {
+			return err
+		}
+		if sent {
+			slog.Warn("already notified",
+				slog.String("video_id", vid),
+				slog.String("keyword", keyword.Name),
+			)
 			continue
 		}
 
 		// キーワードに一致した場合
-		content := fmt.Sprintf("<@&%s>\nhttps://www.youtube.com/watch?v=%s", keyword.RoleID, vid)
-		_, err := discord.ChannelMessageSend(keyword.ChannelID, content)
+		event := &notifier.VideoEvent{
+			Kind:        db.NotificationKindKeyword,
+			Keyword:     keyword.Name,
+			RoleID:      keyword.RoleID,
+			ChannelName: channelName,
+			Video:       videos[0],
+		}
+		bot := &notifier.DiscordBotNotifier{Session: discord, ChannelID: keyword.ChannelID, Build: discordmessage.AnnounceMessage}
+		msgID, err := bot.Notify(event)
+
+		// 送信結果を記録
+		n := db.Notification{
+			VideoID: vid,
+			Target:  keyword.ChannelID,
+			Kind:    db.NotificationKindKeyword,
+			Status:  db.NotificationStatusSent,
+		}
+		if err != nil {
+			n.Status = db.NotificationStatusFailed
+			n.Error = err.Error()
+		} else {
+			n.MessageID = msgID
+		}
+		if serr := cdb.SaveNotification(n); serr != nil {
+			slog.Error(serr.Error(),
+				slog.String("video_id", vid),
+				slog.String("keyword", keyword.Name),
+			)
+		}
+		// 他のキーワードの告知を続け、最後にまとめてエラーを返す
+		// 送信済みのキーワードは記録しているため、リトライしても二重に送信されない
+		if err != nil {
+			merr = multierror.Append(merr, fmt.Errorf("%s: %w", keyword.Name, err))
+			continue
+		}
+
+		// キーワードごとに設定された通知先、Slackにも送信する　失敗してもDiscordの告知には影響させない
+		targets, err := cdb.GetNotifyTargets(notifier.ScopeKeyword, keyword.Name)
 		if err != nil {
 			return err
 		}
+		notifiers := notifier.FromTargets(targets, discordmessage.WebhookMessage)
+		if keyword.SlackWebhookURL != "" {
+			notifiers = append(notifiers, &notifier.SlackNotifier{URL: keyword.SlackWebhookURL})
+		}
+		if err := notifier.NotifyAll(notifiers, event); err != nil {
+			targetErr = multierror.Append(targetErr, fmt.Errorf("%s: %w", keyword.Name, err))
+		}
 	}
 
-	return nil
+	// 通知先への送信の失敗ではジョブを失敗させない
+	if targetErr != nil {
+		slog.Warn(targetErr.Error(),
+			slog.String("video_id", vid),
+		)
+	}
+	return merr.ErrorOrNil()
+}
+
+// 動画のチャンネルがキーワードの通知対象か
+// 除外するチャンネル、グループに含まれる場合は通知しない
+// 通知するチャンネル、グループが指定されている場合は、どちらかに含まれる場合のみ通知する
+func MatchChannel(keyword db.Keyword, cid string, groups []string) bool {
+	if slices.Contains(keyword.DenyChannels, cid) || containsGroup(keyword.DenyGroups, groups) {
+		return false
+	}
+	if len(keyword.AllowChannels) == 0 && len(keyword.AllowGroups) == 0 {
+		return true
+	}
+	return slices.Contains(keyword.AllowChannels, cid) || containsGroup(keyword.AllowGroups, groups)
+}
+
+// グループ名は大文字小文字を区別しない
+func containsGroup(targets []string, groups []string) bool {
+	for _, t := range targets {
+		for _, g := range groups {
+			if strings.EqualFold(t, g) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// キーワードの条件式に一致し、除外する条件式に一致しないか
+func MatchKeyword(keyword db.Keyword, doc *match.Document) bool {
+	// 登録時に検証しているため、解析できない条件式はログを出して無視する
+	ok, err := match.MatchAny(keyword.Include, doc)
+	if err != nil {
+		slog.Warn(err.Error(),
+			slog.String("keyword", keyword.Name),
+		)
+	}
+	if !ok {
+		return false
+	}
+
+	ignored, err := match.MatchAny(keyword.Ignore, doc)
+	if err != nil {
+		slog.Warn(err.Error(),
+			slog.String("keyword", keyword.Name),
+		)
+	}
+	return !ignored
 }
@@ -3,6 +3,7 @@ package discordnotice
 import (
 	"testing"
 
+	"github.com/aopontann/niji-tuu/internal/common/db"
 	"github.com/joho/godotenv"
 )
 
@@ -14,3 +15,29 @@ func TestDiscordAnnounceJob(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestMatchChannel(t *testing.T) {
+	en := []string{"NIJISANJI EN", "NOCTYX"}
+
+	cases := []struct {
+		name    string
+		keyword db.Keyword
+		cid     string
+		groups  []string
+		want    bool
+	}{
+		{"no filter", db.Keyword{}, "UC1", nil, true},
+		{"allow channel", db.Keyword{AllowChannels: []string{"UC1", "UC2"}}, "UC1", nil, true},
+		{"not allowed channel", db.Keyword{AllowChannels: []string{"UC2"}}, "UC1", nil, false},
+		{"allow group", db.Keyword{AllowGroups: []string{"nijisanji en"}}, "UC1", en, true},
+		{"allow group or channel", db.Keyword{AllowChannels: []string{"UC2"}, AllowGroups: []string{"NIJISANJI EN"}}, "UC1", en, true},
+		{"not allowed group", db.Keyword{AllowGroups: []string{"VirtuaReal"}}, "UC1", en, false},
+		{"deny channel", db.Keyword{DenyChannels: []string{"UC1"}}, "UC1", en, false},
+		{"deny group wins over allow channel", db.Keyword{AllowChannels: []string{"UC1"}, DenyGroups: []string{"NOCTYX"}}, "UC1", en, false},
+	}
+	for _, c := range cases {
+		if got := MatchChannel(c.keyword, c.cid, c.groups); got != c.want {
+			t.Errorf("%s: MatchChannel() = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
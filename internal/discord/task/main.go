@@ -7,8 +7,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/aopontann/niji-tuu/internal/common/db"
 	"github.com/aopontann/niji-tuu/internal/common/task"
 	"github.com/aopontann/niji-tuu/internal/common/youtube"
+	"github.com/aopontann/niji-tuu/internal/common/yturl"
 )
 
 func Handler(w http.ResponseWriter, r *http.Request) {
@@ -20,7 +22,14 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := CreateTaskToNoficationByDiscord(strings.Split(vids, ","))
+	parsed, err := yturl.VideoIDs(vids)
+	if err != nil {
+		slog.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = CreateTaskToNoficationByDiscord(parsed)
 	if err != nil {
 		slog.Error(err.Error())
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -36,10 +45,16 @@ func CreateTaskToNoficationByDiscord(vids []string) error {
 	if err != nil {
 		return err
 	}
-	ctask, err := task.NewTask()
+	cdb, err := db.NewDB(os.Getenv("DSN"))
+	if err != nil {
+		return err
+	}
+	defer cdb.Close()
+	ctask, err := task.NewScheduler(cdb)
 	if err != nil {
 		return err
 	}
+	defer ctask.Close()
 
 	videos, err := yt.Videos(vids)
 	if err != nil {
@@ -48,7 +63,7 @@ func CreateTaskToNoficationByDiscord(vids []string) error {
 
 	// discord から通知するタスクを登録
 	for _, v := range videos {
-		err = ctask.Create(&task.TaskInfo{
+		err = ctask.Replace(&task.TaskInfo{
 			Video:      v,
 			QueueID:    os.Getenv("DISCORD_QUEUE_ID"),
 			URL:        os.Getenv("DISCORD_URL"),
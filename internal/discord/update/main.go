@@ -0,0 +1,149 @@
+package discordupdate
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/aopontann/niji-tuu/internal/common/db"
+	"github.com/aopontann/niji-tuu/internal/common/youtube"
+	discordmessage "github.com/aopontann/niji-tuu/internal/discord/message"
+	"github.com/bwmarrin/discordgo"
+	yt "google.golang.org/api/youtube/v3"
+)
+
+// 何日前までの告知を更新対象にするか
+const updateDays = 3
+
+func Handler(w http.ResponseWriter, r *http.Request) {
+	err := UpdateAnnouncementJob()
+	if err != nil {
+		slog.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Discordで告知したメッセージを、配信開始、配信終了、削除された状態に編集する
+// 編集ではメンションされないため、購読者に再度通知はされない
+func UpdateAnnouncementJob() error {
+	yt, err := youtube.NewYoutube(os.Getenv("YOUTUBE_API_KEY"))
+	if err != nil {
+		return err
+	}
+	cdb, err := db.NewDB(os.Getenv("DSN"))
+	if err != nil {
+		return err
+	}
+	defer cdb.Close()
+	discord, err := discordgo.New("Bot " + os.Getenv("DISCORD_BOT_TOKEN"))
+	if err != nil {
+		return err
+	}
+
+	notifications, err := cdb.GetAnnouncedNotifications(time.Now().UTC().AddDate(0, 0, -updateDays))
+	if err != nil {
+		return err
+	}
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	var vids []string
+	for _, n := range notifications {
+		if !slices.Contains(vids, n.VideoID) {
+			vids = append(vids, n.VideoID)
+		}
+	}
+
+	videos, err := yt.Videos(vids)
+	if err != nil {
+		return err
+	}
+	fetched := videoMap(videos)
+
+	// 削除された動画のタイトルを表示するため、DBに登録されている動画情報を取得
+	stored, err := cdb.GetVideos(vids)
+	if err != nil {
+		return err
+	}
+	titles := make(map[string]string, len(stored))
+	for _, v := range stored {
+		titles[v.ID] = v.Title
+	}
+
+	channelNames := make(map[string]string)
+
+	for _, n := range notifications {
+		var embed *discordgo.MessageEmbed
+		status := discordmessage.StatusDeleted
+
+		v, ok := fetched[n.VideoID]
+		if ok {
+			status = discordmessage.VideoStatus(v)
+		}
+		if status == n.VideoStatus {
+			continue
+		}
+
+		// 生放送、プレミア公開ではない動画は状態が変わらないため、編集せずに更新対象から外す
+		if ok && v.LiveStreamingDetails == nil {
+			n.VideoStatus = discordmessage.StatusEnded
+			if err := cdb.UpdateNotificationVideoStatus(n); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if ok {
+			cid := v.Snippet.ChannelId
+			if _, exists := channelNames[cid]; !exists {
+				name, err := cdb.GetVtuberName(cid)
+				if err != nil {
+					slog.Warn(err.Error())
+				}
+				channelNames[cid] = name
+			}
+			embed = discordmessage.NewStatusEmbed(v, channelNames[cid], status)
+		} else {
+			embed = discordmessage.NewDeletedEmbed(n.VideoID, titles[n.VideoID])
+		}
+
+		_, err := discord.ChannelMessageEditComplex(&discordgo.MessageEdit{
+			ID:      n.MessageID,
+			Channel: n.Target,
+			Embeds:  &[]*discordgo.MessageEmbed{embed},
+		})
+		if err != nil {
+			// メッセージが削除されている場合などは、他の告知の更新を続ける
+			slog.Warn(err.Error(),
+				slog.String("video_id", n.VideoID),
+				slog.String("message_id", n.MessageID),
+			)
+			continue
+		}
+
+		slog.Info("update-announcement",
+			slog.String("video_id", n.VideoID),
+			slog.String("old_status", n.VideoStatus),
+			slog.String("new_status", status),
+		)
+
+		n.VideoStatus = status
+		if err := cdb.UpdateNotificationVideoStatus(n); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 動画IDをキー、動画情報を値とした連想配列を返す
+func videoMap(videos []yt.Video) map[string]yt.Video {
+	m := make(map[string]yt.Video, len(videos))
+	for _, v := range videos {
+		m[v.Id] = v
+	}
+	return m
+}
@@ -173,6 +173,7 @@ func GetStatusChengedVtubers(yt *youtube.Youtube, cdb *db.DB) ([]db.Vtuber, erro
 				Name:              vt.Name,
 				ItemCount:         newPlaylists[pid].ItemCount,
 				PlaylistLatestUrl: newPlaylists[pid].Url,
+				Groups:            vt.Groups,
 				CreatedAt:         vt.CreatedAt,
 				UpdatedAt:         time.Now(),
 			})
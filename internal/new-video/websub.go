@@ -0,0 +1,387 @@
+package newvideo
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aopontann/niji-tuu/internal/common/db"
+	"github.com/aopontann/niji-tuu/internal/common/youtube"
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/go-retryablehttp"
+	yt "google.golang.org/api/youtube/v3"
+)
+
+const (
+	hubURL   = "https://pubsubhubbub.appspot.com/subscribe"
+	topicURL = "https://www.youtube.com/xml/feeds/videos.xml?channel_id="
+
+	// ハブが許可している最大のリース期間（10日）
+	leaseSeconds = 864000
+	// 有効期限の何時間前から再購読するか
+	renewBefore = 48 * time.Hour
+)
+
+// WebSub(PubSubHubbub)のハブから送られてくるリクエストを処理する
+// GET は購読確認、POST は新着動画の通知
+func WebSubHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		VerifySubscription(w, r)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		msg := "GETまたはPOSTメソッドでリクエストしてください"
+		slog.Error(msg)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		slog.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// 秘密鍵がない場合は誰でも署名を作れるため、通知を処理しない
+	if os.Getenv("WEBSUB_SECRET") == "" {
+		slog.Error("WEBSUB_SECRET が設定されていないため、WebSubの通知を無視しました")
+		return
+	}
+
+	// 署名が不正な場合でも、仕様上は2xxを返して通知を無視する
+	if !VerifySignature(body, r.Header.Get("X-Hub-Signature"), os.Getenv("WEBSUB_SECRET")) {
+		slog.Warn("WebSubの署名が一致しません",
+			slog.String("signature", r.Header.Get("X-Hub-Signature")),
+		)
+		return
+	}
+
+	var feed youtube.Feed
+	if err := xml.Unmarshal(body, &feed); err != nil {
+		slog.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var vids []string
+	for _, entry := range feed.Entry {
+		if entry.VideoId != "" && !slices.Contains(vids, entry.VideoId) {
+			vids = append(vids, entry.VideoId)
+		}
+	}
+
+	err = PushNewVideoJob(vids)
+	if err != nil {
+		slog.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// ハブからの購読確認リクエストに hub.challenge を返し、購読期限をDBに登録する
+// 登録されていないチャンネルの購読確認は 404 を返して拒否する
+// 購読解除、ハブに購読を拒否された場合は購読情報を削除する
+func VerifySubscription(w http.ResponseWriter, r *http.Request) {
+	mode := r.FormValue("hub.mode")
+	topic := r.FormValue("hub.topic")
+	challenge := r.FormValue("hub.challenge")
+
+	cid, ok := channelIDFromTopic(topic)
+	if !ok || (mode != "subscribe" && mode != "unsubscribe" && mode != "denied") || (mode != "denied" && challenge == "") {
+		msg := "購読確認のパラメータが不正です"
+		slog.Error(msg,
+			slog.String("mode", mode),
+			slog.String("topic", topic),
+		)
+		http.Error(w, msg, http.StatusNotFound)
+		return
+	}
+
+	cdb, err := db.NewDB(os.Getenv("DSN"))
+	if err != nil {
+		slog.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer cdb.Close()
+
+	switch mode {
+	case "denied":
+		// 拒否の通知には hub.challenge がなく、応答も不要
+		slog.Warn("websub-denied",
+			slog.String("channel_id", cid),
+			slog.String("reason", r.FormValue("hub.reason")),
+		)
+		if err := cdb.DeleteSubscription(cid); err != nil {
+			slog.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	case "subscribe":
+		known, err := cdb.ExistsVtuber(cid)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !known {
+			slog.Warn("登録されていないチャンネルの購読確認を拒否しました",
+				slog.String("channel_id", cid),
+			)
+			http.Error(w, "unknown channel", http.StatusNotFound)
+			return
+		}
+
+		lease, err := strconv.ParseInt(r.FormValue("hub.lease_seconds"), 10, 64)
+		if err != nil {
+			slog.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = cdb.SaveSubscription(db.Subscription{
+			ChannelID:    cid,
+			LeaseSeconds: lease,
+			ExpiresAt:    time.Now().UTC().Add(time.Duration(lease) * time.Second),
+		})
+		if err != nil {
+			slog.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	case "unsubscribe":
+		// vtubers から削除されたチャンネルも購読解除できるように、購読情報があるチャンネルも受け付ける
+		known, err := cdb.ExistsVtuber(cid)
+		if err == nil && !known {
+			known, err = cdb.ExistsSubscription(cid)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !known {
+			slog.Warn("登録されていないチャンネルの購読解除を拒否しました",
+				slog.String("channel_id", cid),
+			)
+			http.Error(w, "unknown channel", http.StatusNotFound)
+			return
+		}
+
+		if err := cdb.DeleteSubscription(cid); err != nil {
+			slog.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	slog.Info("websub-verify",
+		slog.String("mode", mode),
+		slog.String("channel_id", cid),
+	)
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(challenge))
+}
+
+// hub.topic からチャンネルIDを取得する
+func channelIDFromTopic(topic string) (string, bool) {
+	cid, ok := strings.CutPrefix(topic, topicURL)
+	if !ok || cid == "" {
+		return "", false
+	}
+	return cid, true
+}
+
+// X-Hub-Signature ヘッダー（sha1=署名）が本文のHMACと一致するか
+// 秘密鍵が空の場合は常に一致しないとみなす
+func VerifySignature(body []byte, signature string, secret string) bool {
+	if secret == "" {
+		return false
+	}
+	sig, ok := strings.CutPrefix(signature, "sha1=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// WebSubで通知された動画IDのうち、未登録の動画をDBに登録して後続の処理に送る
+func PushNewVideoJob(vids []string) error {
+	if len(vids) == 0 {
+		return nil
+	}
+
+	yt, err := youtube.NewYoutube(os.Getenv("YOUTUBE_API_KEY"))
+	if err != nil {
+		return err
+	}
+	cdb, err := db.NewDB(os.Getenv("DSN"))
+	if err != nil {
+		return err
+	}
+	defer cdb.Close()
+
+	// タイトル変更などでも通知が来るため、DBに登録されていない動画のみにフィルター
+	newVIDs, err := cdb.NotExistsVideoID(vids)
+	if err != nil {
+		return err
+	}
+	if len(newVIDs) == 0 {
+		return nil
+	}
+
+	slog.Info("websub-new-video-ids",
+		slog.String("video_id", strings.Join(newVIDs, ",")),
+	)
+
+	videos, err := yt.Videos(newVIDs)
+	if err != nil {
+		return err
+	}
+
+	// 登録されているチャンネル以外の動画は通知しない
+	vtubers, err := cdb.GetVtubers()
+	if err != nil {
+		return err
+	}
+	videos = filterVtuberVideos(videos, vtubers)
+
+	// メン限、限定公開の動画情報はAPIの仕様上取得できない
+	if len(videos) == 0 {
+		return nil
+	}
+
+	ctx := context.Background()
+	tx, err := cdb.Service.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return err
+	}
+	err = cdb.SaveVideos(videos, &tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	var savedVIDs []string
+	for _, v := range videos {
+		savedVIDs = append(savedVIDs, v.Id)
+	}
+	return NewVideoWebHook(savedVIDs)
+}
+
+// vtubers テーブルに登録されているチャンネルの動画のみ返す
+func filterVtuberVideos(videos []yt.Video, vtubers []db.Vtuber) []yt.Video {
+	cids := make(map[string]bool, len(vtubers))
+	for _, v := range vtubers {
+		cids[v.ID] = true
+	}
+
+	var filtered []yt.Video
+	for _, v := range videos {
+		if v.Snippet == nil || !cids[v.Snippet.ChannelId] {
+			slog.Warn("登録されていないチャンネルの動画を無視しました",
+				slog.String("video_id", v.Id),
+			)
+			continue
+		}
+		filtered = append(filtered, v)
+	}
+	return filtered
+}
+
+func RenewSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	err := RenewSubscriptionJob()
+	if err != nil {
+		slog.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// 未購読、または購読期限が近いチャンネルをハブに再購読する
+func RenewSubscriptionJob() error {
+	cdb, err := db.NewDB(os.Getenv("DSN"))
+	if err != nil {
+		return err
+	}
+	defer cdb.Close()
+
+	cids, err := cdb.ExpiringSubscriptionChannelIDs(time.Now().UTC().Add(renewBefore))
+	if err != nil {
+		return err
+	}
+
+	slog.Info("websub-renew",
+		slog.Int("count", len(cids)),
+	)
+
+	return Subscribe(cids)
+}
+
+// 指定したチャンネルの新着動画通知をハブに購読リクエストする
+// 購読確認はハブから非同期に送られてくるため、期限の登録は VerifySubscription で行う
+func Subscribe(cids []string) error {
+	// 秘密鍵がないと通知の署名を検証できないため、購読しない
+	secret := os.Getenv("WEBSUB_SECRET")
+	if secret == "" {
+		return fmt.Errorf("WEBSUB_SECRET が設定されていません")
+	}
+
+	retryClient := retryablehttp.NewClient()
+	retryClient.RetryMax = 2
+	retryClient.Logger = slog.Default()
+
+	var meg multierror.Group
+
+	for _, cid := range cids {
+		form := url.Values{
+			"hub.callback":      {os.Getenv("WEBSUB_CALLBACK_URL")},
+			"hub.topic":         {topicURL + cid},
+			"hub.mode":          {"subscribe"},
+			"hub.verify":        {"async"},
+			"hub.secret":        {secret},
+			"hub.lease_seconds": {strconv.Itoa(leaseSeconds)},
+		}
+		meg.Go(func() error {
+			resp, err := retryClient.PostForm(hubURL, form)
+			if err != nil {
+				slog.Error(err.Error())
+				return err
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+
+			// 非同期の購読確認の場合は 202 Accepted が返ってくる
+			if resp.StatusCode/100 != 2 {
+				return fmt.Errorf("failed to subscribe channel %s: %d %s", cid, resp.StatusCode, string(body))
+			}
+			return nil
+		})
+	}
+
+	merr := meg.Wait()
+	return merr.ErrorOrNil()
+}
@@ -0,0 +1,86 @@
+package newvideo
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aopontann/niji-tuu/internal/common/db"
+	yt "google.golang.org/api/youtube/v3"
+)
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte(`<feed><entry><yt:videoId>EgaXyUcsM48</yt:videoId></entry></feed>`)
+	mac := hmac.New(sha1.New, []byte("secret"))
+	mac.Write(body)
+	signature := "sha1=" + hex.EncodeToString(mac.Sum(nil))
+
+	if !VerifySignature(body, signature, "secret") {
+		t.Error("expected valid signature")
+	}
+	if VerifySignature(body, signature, "other") {
+		t.Error("expected invalid signature with other secret")
+	}
+	if VerifySignature(body, "sha256=abc", "secret") {
+		t.Error("expected invalid signature with unknown prefix")
+	}
+
+	// 秘密鍵が空の署名は誰でも作れるため拒否する
+	mac = hmac.New(sha1.New, nil)
+	mac.Write(body)
+	if VerifySignature(body, "sha1="+hex.EncodeToString(mac.Sum(nil)), "") {
+		t.Error("expected invalid signature with empty secret")
+	}
+}
+
+func TestSubscribeRequiresSecret(t *testing.T) {
+	t.Setenv("WEBSUB_SECRET", "")
+	if err := Subscribe([]string{"UCSFCh5NL4qXrAy9u-u2lX3g"}); err == nil {
+		t.Error("Subscribe() expected error without secret")
+	}
+}
+
+func TestFilterVtuberVideos(t *testing.T) {
+	videos := []yt.Video{
+		{Id: "EgaXyUcsM48", Snippet: &yt.VideoSnippet{ChannelId: "UCSFCh5NL4qXrAy9u-u2lX3g"}},
+		{Id: "dQw4w9WgXcQ", Snippet: &yt.VideoSnippet{ChannelId: "UCuAXFkgsw1L7xaCfnd5JJOw"}},
+		{Id: "C56ImfpThK0"},
+	}
+	got := filterVtuberVideos(videos, []db.Vtuber{{ID: "UCSFCh5NL4qXrAy9u-u2lX3g"}})
+	if len(got) != 1 || got[0].Id != "EgaXyUcsM48" {
+		t.Errorf("filterVtuberVideos() = %v", got)
+	}
+}
+
+func TestChannelIDFromTopic(t *testing.T) {
+	if cid, ok := channelIDFromTopic(topicURL + "UCSFCh5NL4qXrAy9u-u2lX3g"); !ok || cid != "UCSFCh5NL4qXrAy9u-u2lX3g" {
+		t.Errorf("channelIDFromTopic() = %q %v", cid, ok)
+	}
+	for _, topic := range []string{"", topicURL, "https://example.com/feed?channel_id=UCSFCh5NL4qXrAy9u-u2lX3g"} {
+		if _, ok := channelIDFromTopic(topic); ok {
+			t.Errorf("channelIDFromTopic(%q) expected false", topic)
+		}
+	}
+}
+
+// 不正なパラメータはDBに接続する前に拒否する
+func TestVerifySubscriptionRejectsInvalidParams(t *testing.T) {
+	t.Setenv("DSN", "invalid")
+	topic := url.QueryEscape(topicURL + "UCSFCh5NL4qXrAy9u-u2lX3g")
+	for _, query := range []string{
+		"hub.mode=subscribe&hub.topic=" + topic,
+		"hub.mode=other&hub.topic=" + topic + "&hub.challenge=abc",
+		"hub.mode=subscribe&hub.topic=https%3A%2F%2Fexample.com&hub.challenge=abc",
+	} {
+		w := httptest.NewRecorder()
+		VerifySubscription(w, httptest.NewRequest(http.MethodGet, "/?"+query, nil))
+		if w.Code != http.StatusNotFound || strings.Contains(w.Body.String(), "abc") {
+			t.Errorf("VerifySubscription(%s) = %d %q", query, w.Code, w.Body.String())
+		}
+	}
+}
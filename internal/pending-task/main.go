@@ -0,0 +1,117 @@
+package pendingtask
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+
+	"github.com/aopontann/niji-tuu/internal/common/db"
+	"github.com/aopontann/niji-tuu/internal/common/task"
+	"github.com/aopontann/niji-tuu/internal/common/youtube"
+	yt "google.golang.org/api/youtube/v3"
+)
+
+func Handler(w http.ResponseWriter, r *http.Request) {
+	err := PromotePendingTasksJob()
+	if err != nil {
+		slog.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// 保留中のタスクのうち、Cloud Tasks に登録できる範囲になったタスクを登録する
+// 登録する前に動画情報を再取得して、公開予定時刻を確認し直す
+func PromotePendingTasksJob() error {
+	yt, err := youtube.NewYoutube(os.Getenv("YOUTUBE_API_KEY"))
+	if err != nil {
+		return err
+	}
+	cdb, err := db.NewDB(os.Getenv("DSN"))
+	if err != nil {
+		return err
+	}
+	defer cdb.Close()
+	ctask, err := task.NewUndeferredScheduler(cdb)
+	if err != nil {
+		return err
+	}
+	defer ctask.Close()
+
+	pendings, err := cdb.GetPendingTasks()
+	if err != nil {
+		return err
+	}
+	if len(pendings) == 0 {
+		return nil
+	}
+
+	var vids []string
+	for _, pt := range pendings {
+		if !slices.Contains(vids, pt.VideoID) {
+			vids = append(vids, pt.VideoID)
+		}
+	}
+
+	videos, err := yt.Videos(vids)
+	if err != nil {
+		return err
+	}
+	fetched := videoMap(videos)
+
+	for _, pt := range pendings {
+		v, ok := fetched[pt.VideoID]
+		// 削除、非公開にされた動画、または公開予定ではなくなった動画は登録しない
+		if !ok || v.Snippet.LiveBroadcastContent != "upcoming" {
+			slog.Warn("保留中のタスクを削除します",
+				slog.String("video_id", pt.VideoID),
+			)
+			if err := cdb.DeletePendingTask(pt); err != nil {
+				return err
+			}
+			// 保留中として記録している通知を取り消し済みにする
+			if err := cdb.CancelReminders(pt.QueueID, pt.VideoID); err != nil {
+				return err
+			}
+			continue
+		}
+
+		info := task.PendingTaskInfo(pt, v)
+		err := ctask.Create(info)
+		// まだ登録できない場合は、再取得した公開予定時刻で保留し直す
+		if errors.Is(err, task.ErrOutOfRange) {
+			pt.StartTime = db.ScheduledStartTime(v)
+			if err := cdb.SavePendingTask(pt); err != nil {
+				return err
+			}
+			// /song list などで表示する通知時刻も更新する
+			if err := cdb.SaveReminder(task.NewReminder(info, db.ReminderStatusDeferred)); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		slog.Info("promote-pending-task",
+			slog.String("video_id", v.Id),
+			slog.String("title", v.Snippet.Title),
+		)
+		if err := cdb.DeletePendingTask(pt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 動画IDをキー、動画情報を値とした連想配列を返す
+func videoMap(videos []yt.Video) map[string]yt.Video {
+	m := make(map[string]yt.Video, len(videos))
+	for _, v := range videos {
+		m[v.Id] = v
+	}
+	return m
+}
@@ -0,0 +1,170 @@
+package reschedule
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aopontann/niji-tuu/internal/common/db"
+	"github.com/aopontann/niji-tuu/internal/common/task"
+	"github.com/aopontann/niji-tuu/internal/common/youtube"
+	newvideo "github.com/aopontann/niji-tuu/internal/new-video"
+	yt "google.golang.org/api/youtube/v3"
+)
+
+func Handler(w http.ResponseWriter, r *http.Request) {
+	err := CheckRescheduleJob()
+	if err != nil {
+		slog.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// 公開予定の動画情報を再取得し、公開予定時刻が変更された動画のタスクを登録し直す
+// 削除、非公開にされた動画のタスクは削除する
+func CheckRescheduleJob() error {
+	yt, err := youtube.NewYoutube(os.Getenv("YOUTUBE_API_KEY"))
+	if err != nil {
+		return err
+	}
+	cdb, err := db.NewDB(os.Getenv("DSN"))
+	if err != nil {
+		return err
+	}
+	defer cdb.Close()
+
+	stored, err := cdb.GetUpcomingVideos()
+	if err != nil {
+		return err
+	}
+	if len(stored) == 0 {
+		return nil
+	}
+
+	var vids []string
+	for _, v := range stored {
+		vids = append(vids, v.ID)
+	}
+
+	videos, err := yt.Videos(vids)
+	if err != nil {
+		return err
+	}
+
+	updated, reschedules, missing := DiffVideos(stored, videos)
+
+	// 削除、非公開にされた動画は情報を取得できないため、登録済みのタスクを削除する
+	if len(missing) != 0 {
+		slog.Warn("削除、非公開にされた動画が含まれています",
+			slog.String("video_id", strings.Join(missing, ",")),
+		)
+
+		ctask, err := task.NewScheduler(cdb)
+		if err != nil {
+			return err
+		}
+		defer ctask.Close()
+		for _, vid := range missing {
+			for _, queueID := range []string{os.Getenv("SONG_QUEUE_ID"), os.Getenv("DISCORD_QUEUE_ID")} {
+				if err := ctask.Delete(queueID, vid); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	for _, r := range reschedules {
+		slog.Info("rescheduled-video",
+			slog.String("video_id", r.VideoID),
+			slog.Time("old_start_time", r.OldStartTime),
+			slog.Time("new_start_time", r.NewStartTime),
+		)
+	}
+
+	if len(updated) == 0 {
+		return nil
+	}
+
+	// トランザクション開始
+	ctx := context.Background()
+	tx, err := cdb.Service.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return err
+	}
+	err = cdb.UpdateVideos(updated, &tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = cdb.SaveReschedules(reschedules, &tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	// 公開予定時刻が変更された動画は、新着動画と同じ流れでタスクを登録し直す
+	var rvids []string
+	for _, r := range reschedules {
+		rvids = append(rvids, r.VideoID)
+	}
+	return newvideo.NewVideoWebHook(rvids)
+}
+
+// DBに登録されている動画情報と、再取得した動画情報を比較する
+// 更新が必要な動画、公開予定時刻の変更履歴、再取得できなかった動画IDを返す
+func DiffVideos(stored []db.Video, videos []yt.Video) ([]db.Video, []db.Reschedule, []string) {
+	fetched := make(map[string]yt.Video, len(videos))
+	for _, v := range videos {
+		fetched[v.Id] = v
+	}
+
+	var updated []db.Video
+	var reschedules []db.Reschedule
+	var missing []string
+	for _, sv := range stored {
+		v, ok := fetched[sv.ID]
+		if !ok {
+			// 再取得の対象から外すため、配信状態を deleted に更新する
+			missing = append(missing, sv.ID)
+			sv.Content = "deleted"
+			sv.UpdatedAt = time.Now()
+			updated = append(updated, sv)
+			continue
+		}
+
+		startTime := db.ScheduledStartTime(v)
+		rescheduled := !startTime.Equal(sv.StartTime)
+		if !rescheduled && v.Snippet.Title == sv.Title && v.Snippet.LiveBroadcastContent == sv.Content {
+			continue
+		}
+
+		updated = append(updated, db.Video{
+			ID:        sv.ID,
+			Title:     v.Snippet.Title,
+			Duration:  sv.Duration,
+			Content:   v.Snippet.LiveBroadcastContent,
+			StartTime: startTime,
+			CreatedAt: sv.CreatedAt,
+			UpdatedAt: time.Now(),
+		})
+
+		// 配信開始、終了した場合は公開予定時刻の変更として扱わない
+		if rescheduled && v.Snippet.LiveBroadcastContent == "upcoming" {
+			reschedules = append(reschedules, db.Reschedule{
+				VideoID:      sv.ID,
+				OldStartTime: sv.StartTime,
+				NewStartTime: startTime,
+			})
+		}
+	}
+
+	return updated, reschedules, missing
+}
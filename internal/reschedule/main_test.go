@@ -0,0 +1,54 @@
+package reschedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aopontann/niji-tuu/internal/common/db"
+	"google.golang.org/api/youtube/v3"
+)
+
+func TestDiffVideos(t *testing.T) {
+	start := time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)
+	stored := []db.Video{
+		{ID: "aaaaaaaaaaa", Title: "歌枠", Content: "upcoming", StartTime: start},
+		{ID: "bbbbbbbbbbb", Title: "雑談", Content: "upcoming", StartTime: start},
+		{ID: "ccccccccccc", Title: "マイクラ", Content: "upcoming", StartTime: start},
+		{ID: "ddddddddddd", Title: "削除済み", Content: "upcoming", StartTime: start},
+	}
+	videos := []youtube.Video{
+		{
+			Id:                   "aaaaaaaaaaa",
+			Snippet:              &youtube.VideoSnippet{Title: "歌枠", LiveBroadcastContent: "upcoming"},
+			LiveStreamingDetails: &youtube.VideoLiveStreamingDetails{ScheduledStartTime: "2025-04-02T12:00:00Z"},
+		},
+		{
+			Id:                   "bbbbbbbbbbb",
+			Snippet:              &youtube.VideoSnippet{Title: "雑談", LiveBroadcastContent: "upcoming"},
+			LiveStreamingDetails: &youtube.VideoLiveStreamingDetails{ScheduledStartTime: "2025-04-01T12:00:00Z"},
+		},
+		{
+			Id:                   "ccccccccccc",
+			Snippet:              &youtube.VideoSnippet{Title: "マイクラ", LiveBroadcastContent: "live"},
+			LiveStreamingDetails: &youtube.VideoLiveStreamingDetails{ScheduledStartTime: "2025-04-01T12:05:00Z"},
+		},
+	}
+
+	updated, reschedules, missing := DiffVideos(stored, videos)
+
+	if len(updated) != 3 {
+		t.Fatalf("expected 3 updated videos, got %d", len(updated))
+	}
+	if len(reschedules) != 1 || reschedules[0].VideoID != "aaaaaaaaaaa" {
+		t.Fatalf("expected aaaaaaaaaaa to be rescheduled, got %v", reschedules)
+	}
+	if !reschedules[0].NewStartTime.Equal(start.Add(24 * time.Hour)) {
+		t.Errorf("unexpected new start time %s", reschedules[0].NewStartTime)
+	}
+	if len(missing) != 1 || missing[0] != "ddddddddddd" {
+		t.Errorf("expected ddddddddddd to be missing, got %v", missing)
+	}
+	if updated[2].Content != "deleted" {
+		t.Errorf("expected missing video to be marked as deleted, got %s", updated[2].Content)
+	}
+}
@@ -1,14 +1,16 @@
 package songnotice
 
 import (
-	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 
 	"github.com/aopontann/niji-tuu/internal/common/db"
 	"github.com/aopontann/niji-tuu/internal/common/fcm"
+	"github.com/aopontann/niji-tuu/internal/common/notifier"
 	"github.com/aopontann/niji-tuu/internal/common/youtube"
+	"github.com/aopontann/niji-tuu/internal/common/yturl"
+	discordmessage "github.com/aopontann/niji-tuu/internal/discord/message"
 	"github.com/bwmarrin/discordgo"
 )
 
@@ -25,15 +27,21 @@ func HandlerFCM(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	vid := r.FormValue("v")
-	if vid == "" {
+	v := r.FormValue("v")
+	if v == "" {
 		msg := "クエリパラメータ v が指定されていません"
 		slog.Error(msg)
 		http.Error(w, msg, http.StatusBadRequest)
 		return
 	}
+	vid, err := yturl.VideoID(v)
+	if err != nil {
+		slog.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	err := SongVideoAnnounceJob(vid)
+	err = SongVideoAnnounceJob(vid)
 	if err != nil {
 		slog.Error(err.Error())
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -48,15 +56,21 @@ func HandlerDiscord(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	vid := r.FormValue("v")
-	if vid == "" {
+	v := r.FormValue("v")
+	if v == "" {
 		msg := "クエリパラメータ v が指定されていません"
 		slog.Error(msg)
 		http.Error(w, msg, http.StatusBadRequest)
 		return
 	}
+	vid, err := yturl.VideoID(v)
+	if err != nil {
+		slog.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	err := NotifyFromDiscord(vid)
+	err = NotifyFromDiscord(vid)
 	if err != nil {
 		slog.Error(err.Error())
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -88,29 +102,52 @@ func SongVideoAnnounceJob(vid string) error {
 		return nil
 	}
 
+	// Cloud Tasks のリトライで二重に送信しないように、送信済みかチェック
+	sent, err := cdb.NotificationSent(vid, "fcm", db.NotificationKindSongFCM)
+	if err != nil {
+		return err
+	}
+	if sent {
+		slog.Warn("already notified",
+			slog.String("video_id", vid),
+		)
+		return nil
+	}
+
 	// FCMトークンを取得
 	tokens, err := cdb.GetSongTokens()
 	if err != nil {
 		return err
 	}
 
-	title := videos[0].Snippet.Title
-	thumbnail := videos[0].Snippet.Thumbnails.High.Url
-
 	slog.Info("song-video-announce",
 		slog.String("video_id", vid),
-		slog.String("title", title),
+		slog.String("title", videos[0].Snippet.Title),
 	)
 
-	err = cfcm.Notification(
-		"5分後に公開",
-		tokens,
-		&fcm.NotificationVideo{
-			ID:        vid,
-			Title:     title,
-			Thumbnail: thumbnail,
-		},
-	)
+	fcmNotifier := &notifier.FCMNotifier{FCM: cfcm, Tokens: tokens}
+	_, err = fcmNotifier.Notify(&notifier.VideoEvent{
+		Kind:    db.NotificationKindSongFCM,
+		Message: "5分後に公開",
+		Video:   videos[0],
+	})
+
+	// 送信結果を記録
+	n := db.Notification{
+		VideoID: vid,
+		Target:  "fcm",
+		Kind:    db.NotificationKindSongFCM,
+		Status:  db.NotificationStatusSent,
+	}
+	if err != nil {
+		n.Status = db.NotificationStatusFailed
+		n.Error = err.Error()
+	}
+	if serr := cdb.SaveNotification(n); serr != nil {
+		slog.Error(serr.Error(),
+			slog.String("video_id", vid),
+		)
+	}
 	if err != nil {
 		return err
 	}
@@ -125,11 +162,29 @@ func NotifyFromDiscord(vid string) error {
 		return err
 	}
 
+	cdb, err := db.NewDB(os.Getenv("DSN"))
+	if err != nil {
+		return err
+	}
+	defer cdb.Close()
+
 	discord, err := discordgo.New("Bot " + os.Getenv("DISCORD_BOT_TOKEN"))
 	if err != nil {
 		return err
 	}
 
+	// Cloud Tasks のリトライで二重に送信しないように、送信済みかチェック
+	sent, err := cdb.NotificationSent(vid, ChannelID, db.NotificationKindSongDiscord)
+	if err != nil {
+		return err
+	}
+	if sent {
+		slog.Warn("already notified",
+			slog.String("video_id", vid),
+		)
+		return nil
+	}
+
 	// 動画か消されていないかチェック
 	videos, err := yt.Videos([]string{vid})
 	if err != nil {
@@ -150,11 +205,53 @@ func NotifyFromDiscord(vid string) error {
 	)
 
 	// discordから通知
-	content := fmt.Sprintf("<@&%s>\nhttps://www.youtube.com/watch?v=%s", roleID, vid)
-	_, err = discord.ChannelMessageSend(ChannelID, content)
+	// 取得できない場合は YouTube のチャンネル名を表示する
+	channelName, err := cdb.GetVtuberName(video.Snippet.ChannelId)
+	if err != nil {
+		slog.Warn(err.Error())
+	}
+	event := &notifier.VideoEvent{
+		Kind:        db.NotificationKindSongDiscord,
+		Message:     "1時間後に公開",
+		RoleID:      roleID,
+		ChannelName: channelName,
+		Video:       video,
+	}
+	bot := &notifier.DiscordBotNotifier{Session: discord, ChannelID: ChannelID, Build: discordmessage.AnnounceMessage}
+	msgID, err := bot.Notify(event)
+
+	// 送信結果を記録
+	n := db.Notification{
+		VideoID: vid,
+		Target:  ChannelID,
+		Kind:    db.NotificationKindSongDiscord,
+		Status:  db.NotificationStatusSent,
+	}
+	if err != nil {
+		n.Status = db.NotificationStatusFailed
+		n.Error = err.Error()
+	} else {
+		n.MessageID = msgID
+	}
+	if serr := cdb.SaveNotification(n); serr != nil {
+		slog.Error(serr.Error(),
+			slog.String("video_id", vid),
+		)
+	}
 	if err != nil {
 		return err
 	}
 
+	// 歌動画の通知先に設定された送信先にも送信する　失敗してもDiscordの告知には影響させない
+	targets, err := cdb.GetNotifyTargets(notifier.ScopeFeature, notifier.FeatureSong)
+	if err != nil {
+		return err
+	}
+	if err := notifier.NotifyAll(notifier.FromTargets(targets, discordmessage.WebhookMessage), event); err != nil {
+		slog.Warn(err.Error(),
+			slog.String("video_id", vid),
+		)
+	}
+
 	return nil
 }
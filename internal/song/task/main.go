@@ -1,7 +1,6 @@
 package songtask
 
 import (
-	"bytes"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -9,9 +8,15 @@ import (
 	"strings"
 	"time"
 
+	"github.com/aopontann/niji-tuu/internal/common/classifier"
+	"github.com/aopontann/niji-tuu/internal/common/db"
+	"github.com/aopontann/niji-tuu/internal/common/notifier"
 	"github.com/aopontann/niji-tuu/internal/common/task"
 	"github.com/aopontann/niji-tuu/internal/common/youtube"
+	"github.com/aopontann/niji-tuu/internal/common/yturl"
+	discordmessage "github.com/aopontann/niji-tuu/internal/discord/message"
 	"github.com/avast/retry-go/v4"
+	"github.com/bwmarrin/discordgo"
 	multierror "github.com/hashicorp/go-multierror"
 	yt "google.golang.org/api/youtube/v3"
 )
@@ -25,7 +30,14 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := SongVideoCheck(strings.Split(vids, ","))
+	parsed, err := yturl.VideoIDs(vids)
+	if err != nil {
+		slog.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = SongVideoCheck(parsed)
 	if err != nil {
 		slog.Error(err.Error())
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -41,7 +53,17 @@ func SongVideoCheck(vids []string) error {
 	if err != nil {
 		return err
 	}
-	task, err := task.NewTask()
+	cdb, err := db.NewDB(os.Getenv("DSN"))
+	if err != nil {
+		return err
+	}
+	defer cdb.Close()
+	task, err := task.NewScheduler(cdb)
+	if err != nil {
+		return err
+	}
+	defer task.Close()
+	c, err := classifier.NewClassifierFromDB(cdb)
 	if err != nil {
 		return err
 	}
@@ -58,7 +80,7 @@ func SongVideoCheck(vids []string) error {
 	meg.Go(func() error {
 		err = retry.Do(
 			func() error {
-				return SendMailMaybeSongVideos(yt, videos)
+				return SendMailMaybeSongVideos(c, videos)
 			},
 			retry.Attempts(3),
 			retry.Delay(1*time.Second),
@@ -73,7 +95,7 @@ func SongVideoCheck(vids []string) error {
 	meg.Go(func() error {
 		err = retry.Do(
 			func() error {
-				return AddSongTaskToCloudTasks(yt, task, videos)
+				return AddSongTaskToCloudTasks(c, task, videos)
 			},
 			retry.Attempts(3),
 			retry.Delay(1*time.Second),
@@ -91,11 +113,52 @@ func SongVideoCheck(vids []string) error {
 }
 
 // 歌みた動画か判別しづらい動画をメールに送信する
-func SendMailMaybeSongVideos(yt *youtube.Youtube, videos []yt.Video) error {
-	for _, v := range videos {
-		if yt.FindSongKeyword(v) {
-			continue
+// スコアが MAYBE_SONG_SCORE_THRESHOLD 以上、SONG_SCORE_THRESHOLD 未満の動画を送信する
+// DISCORD_REVIEW_CHANNEL_ID が設定されている場合は、Webhook の代わりに Bot から判定ボタン付きで送信する
+func SendMailMaybeSongVideos(c *classifier.Classifier, videos []yt.Video) error {
+	songThreshold, err := classifier.Threshold("SONG_SCORE_THRESHOLD", classifier.DefaultSongThreshold)
+	if err != nil {
+		return err
+	}
+	maybeThreshold, err := classifier.Threshold("MAYBE_SONG_SCORE_THRESHOLD", classifier.DefaultMaybeSongThreshold)
+	if err != nil {
+		return err
+	}
+
+	cdb, err := db.NewDB(os.Getenv("DSN"))
+	if err != nil {
+		return err
+	}
+	defer cdb.Close()
+
+	// 環境変数のWebhookに加えて、機能ごとに設定された通知先にも送信する
+	targets, err := cdb.GetNotifyTargets(notifier.ScopeFeature, notifier.FeatureMaybeSong)
+	if err != nil {
+		return err
+	}
+	var notifiers []maybeSongNotifier
+	for _, t := range targets {
+		for _, n := range notifier.FromTargets([]db.NotifyTarget{t}, discordmessage.WebhookMessage) {
+			notifiers = append(notifiers, maybeSongNotifier{Target: fmt.Sprintf("notify-target:%d", t.ID), Notifier: n})
+		}
+	}
+
+	var review *notifier.DiscordBotNotifier
+	if channelID := os.Getenv("DISCORD_REVIEW_CHANNEL_ID"); channelID != "" {
+		discord, err := discordgo.New("Bot " + os.Getenv("DISCORD_BOT_TOKEN"))
+		if err != nil {
+			return err
 		}
+		review = &notifier.DiscordBotNotifier{Session: discord, ChannelID: channelID, Build: discordmessage.ReviewMessage}
+	} else {
+		notifiers = append(notifiers, maybeSongNotifier{
+			Target:   "DISCORD_WEBHOOK_MAYBE_SONG",
+			Notifier: &notifier.DiscordWebhookNotifier{URL: os.Getenv("DISCORD_WEBHOOK_MAYBE_SONG"), Build: discordmessage.WebhookMessage},
+		})
+	}
+
+	var merr *multierror.Error
+	for _, v := range videos {
 		if v.LiveStreamingDetails == nil {
 			continue
 		}
@@ -105,27 +168,125 @@ func SendMailMaybeSongVideos(yt *youtube.Youtube, videos []yt.Video) error {
 		if v.ContentDetails.Duration == "P0D" {
 			continue
 		}
-		// 特定のキーワードを含んでいる場合
-		if yt.FindIgnoreKeyword(v) {
+		res := c.Classify(v)
+		if res.Score < maybeThreshold || res.Score >= songThreshold {
 			continue
 		}
+		logResult(v, res)
 
-		body := []byte(fmt.Sprintf(`{"content": "https://www.youtube.com/watch?v=%s"}`, v.Id))
-		resp, err := http.Post(
-			os.Getenv("DISCORD_WEBHOOK_MAYBE_SONG"),
-			"application/json",
-			bytes.NewBuffer(body),
-		)
-		if err != nil {
-			return err
+		event := &notifier.VideoEvent{
+			Kind:    notifier.FeatureMaybeSong,
+			Message: "https://www.youtube.com/watch?v=" + v.Id,
+			Video:   v,
+		}
+		if review != nil {
+			if err := sendReview(cdb, review, event, res); err != nil {
+				return err
+			}
+		}
+		// 通知先への送信に失敗しても、他の通知先、他の動画の送信を続ける
+		for _, n := range notifiers {
+			if err := notifyMaybeSong(cdb, n, event); err != nil {
+				merr = multierror.Append(merr, fmt.Errorf("%s: %w", n.Target, err))
+			}
 		}
-		resp.Body.Close()
+	}
+	// 通知先への送信の失敗ではジョブを失敗させない
+	if merr != nil {
+		slog.Warn(merr.Error())
 	}
 	return nil
 }
 
+// 歌動画か判別しづらい動画の通知先
+type maybeSongNotifier struct {
+	// 送信結果を記録する notifications.target　通知先のIDか環境変数名
+	Target   string
+	Notifier notifier.Notifier
+}
+
+// 通知先に送信する
+// SendMailMaybeSongVideos のリトライで同じ動画を二重に送信しないように、通知先ごとに送信結果を記録する
+func notifyMaybeSong(cdb *db.DB, n maybeSongNotifier, event *notifier.VideoEvent) error {
+	vid := event.Video.Id
+	sent, err := cdb.NotificationSent(vid, n.Target, db.NotificationKindMaybeSong)
+	if err != nil {
+		return err
+	}
+	if sent {
+		return nil
+	}
+
+	_, err = n.Notifier.Notify(event)
+
+	record := db.Notification{
+		VideoID: vid,
+		Target:  n.Target,
+		Kind:    db.NotificationKindMaybeSong,
+		Status:  db.NotificationStatusSent,
+	}
+	if err != nil {
+		slog.Error(err.Error(),
+			slog.String("video_id", vid),
+			slog.String("target", n.Target),
+		)
+		record.Status = db.NotificationStatusFailed
+		record.Error = err.Error()
+	}
+	if serr := cdb.SaveNotification(record); serr != nil {
+		slog.Error(serr.Error(),
+			slog.String("video_id", vid),
+		)
+	}
+	return err
+}
+
+// 判定ボタン付きのメッセージを送信する
+// リトライで同じ動画を二重に送信しないように、送信結果を記録する
+func sendReview(cdb *db.DB, review *notifier.DiscordBotNotifier, event *notifier.VideoEvent, res *classifier.Result) error {
+	vid := event.Video.Id
+	sent, err := cdb.NotificationSent(vid, review.ChannelID, db.NotificationKindSongReview)
+	if err != nil {
+		return err
+	}
+	if sent {
+		return nil
+	}
+
+	msgID, err := review.Notify(&notifier.VideoEvent{
+		Kind:    db.NotificationKindSongReview,
+		Message: fmt.Sprintf("歌動画か判定してください（スコア %g）", res.Score),
+		Video:   event.Video,
+	})
+
+	n := db.Notification{
+		VideoID: vid,
+		Target:  review.ChannelID,
+		Kind:    db.NotificationKindSongReview,
+		Status:  db.NotificationStatusSent,
+	}
+	if err != nil {
+		n.Status = db.NotificationStatusFailed
+		n.Error = err.Error()
+	} else {
+		n.MessageID = msgID
+	}
+	if serr := cdb.SaveNotification(n); serr != nil {
+		slog.Error(serr.Error(),
+			slog.String("video_id", vid),
+		)
+	}
+	return err
+}
+
 // cloud task に歌みた告知タスクを登録
-func AddSongTaskToCloudTasks(yt *youtube.Youtube, ctask *task.Task, videos []yt.Video) error {
+// スコアが SONG_SCORE_THRESHOLD 以上の動画を歌動画として扱う
+func AddSongTaskToCloudTasks(c *classifier.Classifier, ctask task.Scheduler, videos []yt.Video) error {
+	threshold, err := classifier.Threshold("SONG_SCORE_THRESHOLD", classifier.DefaultSongThreshold)
+	if err != nil {
+		return err
+	}
+
 	for _, v := range videos {
 		// 生放送ではない、プレミア公開されない動画の場合
 		if v.LiveStreamingDetails == nil {
@@ -139,9 +300,11 @@ func AddSongTaskToCloudTasks(yt *youtube.Youtube, ctask *task.Task, videos []yt.
 		if v.ContentDetails.Duration == "P0D" {
 			continue
 		}
-		if !yt.FindSongKeyword(v) || yt.FindIgnoreKeyword(v) {
+		res := c.Classify(v)
+		if res.Score < threshold {
 			continue
 		}
+		logResult(v, res)
 
 		taskInfoFCM := &task.TaskInfo{
 			Video:      v,
@@ -156,14 +319,24 @@ func AddSongTaskToCloudTasks(yt *youtube.Youtube, ctask *task.Task, videos []yt.
 			MinutesAgo: time.Hour * 1,
 		}
 
-		if err := ctask.Create(taskInfoFCM); err != nil {
+		if err := ctask.Replace(taskInfoFCM); err != nil {
 			slog.Error(err.Error())
 			return err
 		}
-		if err := ctask.Create(taskInfoDiscord); err != nil {
+		if err := ctask.Replace(taskInfoDiscord); err != nil {
 			slog.Error(err.Error())
 			return err
 		}
 	}
 	return nil
 }
+
+// 判定の根拠を確認できるように、スコアと一致したルールをログに出す
+func logResult(v yt.Video, res *classifier.Result) {
+	slog.Info("classify-song",
+		slog.String("video_id", v.Id),
+		slog.String("title", v.Snippet.Title),
+		slog.Float64("score", res.Score),
+		slog.String("reasons", strings.Join(res.Reasons, ", ")),
+	)
+}
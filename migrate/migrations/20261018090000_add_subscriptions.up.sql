@@ -0,0 +1,12 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+CREATE TABLE "subscriptions" (
+    "channel_id" varchar(24) NOT NULL,
+    "lease_seconds" integer NOT NULL DEFAULT 0,
+    "expires_at" timestamp,
+    "created_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("channel_id")
+);
@@ -0,0 +1,12 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+CREATE TABLE "video_reschedules" (
+    "id" BIGSERIAL NOT NULL,
+    "video_id" varchar(11) NOT NULL,
+    "old_start_time" timestamp,
+    "new_start_time" timestamp,
+    "created_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("id")
+);
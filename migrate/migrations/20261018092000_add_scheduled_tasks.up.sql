@@ -0,0 +1,22 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+CREATE TABLE "scheduled_tasks" (
+    "name" varchar(200) NOT NULL,
+    "queue_id" varchar(100) NOT NULL,
+    "video_id" varchar(11) NOT NULL,
+    "minutes_ago" integer NOT NULL DEFAULT 0,
+    "url" varchar NOT NULL,
+    "schedule_time" timestamp NOT NULL,
+    "status" varchar(20) NOT NULL DEFAULT 'pending',
+    "attempts" integer NOT NULL DEFAULT 0,
+    "last_error" varchar NOT NULL DEFAULT '',
+    "created_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("name")
+);
+
+--bun:split
+
+CREATE INDEX "scheduled_tasks_status_schedule_time_idx" ON "scheduled_tasks" ("status", "schedule_time");
@@ -0,0 +1,14 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+CREATE TABLE "pending_tasks" (
+    "video_id" varchar(11) NOT NULL,
+    "queue_id" varchar(100) NOT NULL,
+    "minutes_ago" integer NOT NULL,
+    "url" varchar NOT NULL,
+    "scheduled_start_time" timestamp,
+    "created_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("video_id", "queue_id", "minutes_ago")
+);
@@ -0,0 +1,15 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+CREATE TABLE "notifications" (
+    "video_id" varchar(11) NOT NULL,
+    "target" varchar(100) NOT NULL,
+    "kind" varchar(30) NOT NULL,
+    "message_id" varchar(30) NOT NULL DEFAULT '',
+    "status" varchar(20) NOT NULL,
+    "error" varchar NOT NULL DEFAULT '',
+    "created_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("video_id", "target", "kind")
+);
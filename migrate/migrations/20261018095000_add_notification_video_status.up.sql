@@ -0,0 +1,5 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+ALTER TABLE notifications ADD COLUMN video_status varchar(20) NOT NULL DEFAULT 'upcoming';
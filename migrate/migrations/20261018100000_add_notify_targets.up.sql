@@ -0,0 +1,19 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+CREATE TABLE "notify_targets" (
+    "id" BIGSERIAL NOT NULL,
+    "scope" varchar(20) NOT NULL,
+    "name" varchar(100) NOT NULL,
+    "type" varchar(30) NOT NULL,
+    "url" varchar NOT NULL,
+    "secret" varchar NOT NULL DEFAULT '',
+    "created_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("id")
+);
+
+--bun:split
+
+CREATE INDEX "notify_targets_scope_name_idx" ON "notify_targets" ("scope", "name");
@@ -0,0 +1,5 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+ALTER TABLE keywords ADD COLUMN slack_webhook_url varchar NOT NULL DEFAULT '';
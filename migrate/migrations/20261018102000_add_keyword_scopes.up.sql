@@ -0,0 +1,5 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+ALTER TABLE keywords ADD COLUMN scopes VARCHAR[] NOT NULL DEFAULT '{title}';
@@ -0,0 +1,21 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+ALTER TABLE keywords DROP COLUMN deny_groups;
+
+--bun:split
+
+ALTER TABLE keywords DROP COLUMN allow_groups;
+
+--bun:split
+
+ALTER TABLE keywords DROP COLUMN deny_channels;
+
+--bun:split
+
+ALTER TABLE keywords DROP COLUMN allow_channels;
+
+--bun:split
+
+ALTER TABLE vtubers DROP COLUMN groups;
@@ -0,0 +1,21 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+ALTER TABLE vtubers ADD COLUMN groups VARCHAR[];
+
+--bun:split
+
+ALTER TABLE keywords ADD COLUMN allow_channels VARCHAR[];
+
+--bun:split
+
+ALTER TABLE keywords ADD COLUMN deny_channels VARCHAR[];
+
+--bun:split
+
+ALTER TABLE keywords ADD COLUMN allow_groups VARCHAR[];
+
+--bun:split
+
+ALTER TABLE keywords ADD COLUMN deny_groups VARCHAR[];
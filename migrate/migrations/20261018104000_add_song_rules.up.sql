@@ -0,0 +1,39 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+CREATE TABLE "song_rules" (
+    "id" BIGSERIAL NOT NULL,
+    "kind" varchar(20) NOT NULL,
+    "value" varchar NOT NULL DEFAULT '',
+    "weight" double precision NOT NULL,
+    "enabled" BOOLEAN NOT NULL DEFAULT true,
+    "created_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("id")
+);
+
+--bun:split
+
+INSERT INTO "song_rules" ("kind", "value", "weight") VALUES
+    ('title', 'cover', 1),
+    ('title', '歌って', 1),
+    ('title', '歌わせて', 1),
+    ('title', 'Original Song', 1),
+    ('title', 'オリジナル曲', 1),
+    ('title', 'オリジナル楽曲', 1),
+    ('title', 'オリジナルソング', 1),
+    ('title', 'MV', 1),
+    ('title', 'Music Video', 1),
+    ('tag', 'cover', 1),
+    ('tag', '歌って', 1),
+    ('tag', '歌わせて', 1),
+    ('tag', 'Original Song', 1),
+    ('tag', 'オリジナル曲', 1),
+    ('tag', 'オリジナル楽曲', 1),
+    ('tag', 'オリジナルソング', 1),
+    ('tag', 'MV', 1),
+    ('tag', 'Music Video', 1),
+    ('title', '切り抜き', -10),
+    ('title', 'ラジオ', -10),
+    ('title', 'くろなん', -10);
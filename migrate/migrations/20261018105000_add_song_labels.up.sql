@@ -0,0 +1,15 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+CREATE TABLE "song_labels" (
+    "video_id" varchar(11) NOT NULL,
+    "title" varchar NOT NULL,
+    "description" varchar NOT NULL DEFAULT '',
+    "tags" VARCHAR[],
+    "is_song" BOOLEAN NOT NULL,
+    "user_id" varchar(20) NOT NULL DEFAULT '',
+    "created_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("video_id")
+);
@@ -0,0 +1,11 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+CREATE TABLE "panels" (
+    "message_id" varchar(20) NOT NULL,
+    "channel_id" varchar(20) NOT NULL,
+    "created_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("message_id")
+);
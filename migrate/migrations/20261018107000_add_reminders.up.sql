@@ -0,0 +1,19 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+CREATE TABLE "reminders" (
+    "video_id" varchar(11) NOT NULL,
+    "queue_id" varchar(100) NOT NULL,
+    "minutes_ago" integer NOT NULL,
+    "url" varchar NOT NULL,
+    "schedule_time" TIMESTAMP(0) NOT NULL,
+    "status" varchar(20) NOT NULL,
+    "created_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("video_id", "queue_id", "minutes_ago")
+);
+
+--bun:split
+
+CREATE INDEX "reminders_queue_id_schedule_time_idx" ON "reminders" ("queue_id", "schedule_time");
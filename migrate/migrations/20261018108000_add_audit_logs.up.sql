@@ -0,0 +1,14 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+CREATE TABLE "audit_logs" (
+    "id" BIGSERIAL NOT NULL,
+    "user_id" varchar(20) NOT NULL DEFAULT '',
+    "guild_id" varchar(20) NOT NULL DEFAULT '',
+    "action" varchar(100) NOT NULL,
+    "result" varchar(20) NOT NULL,
+    "reason" varchar NOT NULL DEFAULT '',
+    "created_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("id")
+);
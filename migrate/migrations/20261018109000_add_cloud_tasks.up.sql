@@ -0,0 +1,17 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+-- Cloud Tasks に登録したタスクの名前を記録する
+-- このテーブルを作成する前に登録されたタスクは記録されていないため、削除、置き換えの対象にならない
+-- 適用後に go run ./cmd/tasks -import を実行して、キューに残っているタスクを記録すること
+CREATE TABLE "cloud_tasks" (
+    "video_id" varchar(11) NOT NULL,
+    "queue_id" varchar(100) NOT NULL,
+    "minutes_ago" integer NOT NULL,
+    "name" varchar NOT NULL,
+    "schedule_time" TIMESTAMP(0) NOT NULL,
+    "created_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("video_id", "queue_id", "minutes_ago")
+);
@@ -0,0 +1,74 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/uptrace/bun"
+
+	"github.com/aopontann/niji-tuu/internal/common/match"
+)
+
+// 条件式を導入する前に登録されたキーワードの語句を、同じ意味の条件式に変換する
+// 新しいボットで条件式を登録する前に適用すること（適用後に登録した条件式も変換されてしまうため）
+// 変換できない語句がある場合は何も変更せずに失敗するため、該当する語句を手動で修正してから適用し直す
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			var keywords []legacyKeyword
+			if err := tx.NewSelect().Model(&keywords).Scan(ctx); err != nil {
+				return err
+			}
+
+			var errs []string
+			for i, k := range keywords {
+				include, err := convertLegacyTerms(k.Include)
+				if err != nil {
+					errs = append(errs, fmt.Sprintf("%s include: %s", k.Name, err.Error()))
+				}
+				ignore, err := convertLegacyTerms(k.Ignore)
+				if err != nil {
+					errs = append(errs, fmt.Sprintf("%s ignore: %s", k.Name, err.Error()))
+				}
+				keywords[i].Include = include
+				keywords[i].Ignore = ignore
+			}
+			if len(errs) != 0 {
+				return fmt.Errorf("条件式に変換できない語句があります\n%s", strings.Join(errs, "\n"))
+			}
+
+			for _, k := range keywords {
+				_, err := tx.NewUpdate().Model(&k).Column("include", "ignore").WherePK().Exec(ctx)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}, func(ctx context.Context, db *bun.DB) error {
+		// 変換前の語句は残していないため、元に戻さない
+		return nil
+	})
+}
+
+// 変換に必要な列のみ扱う
+type legacyKeyword struct {
+	bun.BaseModel `bun:"table:keywords"`
+
+	Name    string   `bun:"name,pk"`
+	Include []string `bun:"include,array"`
+	Ignore  []string `bun:"ignore,array"`
+}
+
+func convertLegacyTerms(terms []string) ([]string, error) {
+	exprs := make([]string, 0, len(terms))
+	for _, term := range terms {
+		expr, err := match.FromLegacy(term)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+	}
+	return exprs, nil
+}
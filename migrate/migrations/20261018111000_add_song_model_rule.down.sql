@@ -0,0 +1,5 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+DELETE FROM "song_rules" WHERE "kind" = 'model' AND "value" = '' AND "enabled" = false;
@@ -0,0 +1,6 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+-- SONG_MODEL_PATH のモデルを使う場合は enabled を true にする
+INSERT INTO "song_rules" ("kind", "value", "weight", "enabled") VALUES ('model', '', 1, false);
@@ -8,7 +8,10 @@ import (
 	discordbot "github.com/aopontann/niji-tuu/internal/discord/bot"
 	discordnotice "github.com/aopontann/niji-tuu/internal/discord/notice"
 	discordtask "github.com/aopontann/niji-tuu/internal/discord/task"
+	discordupdate "github.com/aopontann/niji-tuu/internal/discord/update"
 	newvideo "github.com/aopontann/niji-tuu/internal/new-video"
+	pendingtask "github.com/aopontann/niji-tuu/internal/pending-task"
+	"github.com/aopontann/niji-tuu/internal/reschedule"
 	songnotice "github.com/aopontann/niji-tuu/internal/song/notice"
 	songtask "github.com/aopontann/niji-tuu/internal/song/task"
 )
@@ -34,6 +37,11 @@ func init() {
 	slog.SetDefault(logger)
 
 	functions.HTTP("new-video", newvideo.Handler)
+	functions.HTTP("websub", newvideo.WebSubHandler)
+	functions.HTTP("websub-renew", newvideo.RenewSubscriptionHandler)
+
+	functions.HTTP("reschedule", reschedule.Handler)
+	functions.HTTP("pending-task", pendingtask.Handler)
 
 	functions.HTTP("song-task", songtask.Handler)
 
@@ -43,6 +51,9 @@ func init() {
 	functions.HTTP("song-notice-discord", songnotice.HandlerDiscord)
 
 	functions.HTTP("discord-notice", discordnotice.Handler)
+	functions.HTTP("discord-update", discordupdate.Handler)
 
 	functions.HTTP("discord-bot", discordbot.Handler)
+	functions.HTTP("discord-bot-worker", discordbot.WorkerHandler)
+	functions.HTTP("discord-panel-refresh", discordbot.RefreshPanelsHandler)
 }
@@ -3,6 +3,7 @@ CREATE TABLE "vtubers" (
     "name" varchar NOT NULL,
     "item_count" integer DEFAULT 0,
     "playlist_latest_url" varchar DEFAULT '',
+    "groups" VARCHAR[],
     "created_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
     "updated_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
     PRIMARY KEY ("id")
@@ -34,7 +35,147 @@ CREATE TABLE "keywords" (
     "channel_id" varchar(30),
     "include" VARCHAR[],
     "ignore" VARCHAR[],
+    "scopes" VARCHAR[] NOT NULL DEFAULT '{title}',
+    "allow_channels" VARCHAR[],
+    "deny_channels" VARCHAR[],
+    "allow_groups" VARCHAR[],
+    "deny_groups" VARCHAR[],
+    "slack_webhook_url" varchar NOT NULL DEFAULT '',
     "created_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
     "updated_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
     PRIMARY KEY ("name")
 );
+
+CREATE TABLE "subscriptions" (
+    "channel_id" varchar(24) NOT NULL,
+    "lease_seconds" integer NOT NULL DEFAULT 0,
+    "expires_at" timestamp,
+    "created_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("channel_id")
+);
+
+CREATE TABLE "video_reschedules" (
+    "id" BIGSERIAL NOT NULL,
+    "video_id" varchar(11) NOT NULL,
+    "old_start_time" timestamp,
+    "new_start_time" timestamp,
+    "created_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("id")
+);
+
+CREATE TABLE "scheduled_tasks" (
+    "name" varchar(200) NOT NULL,
+    "queue_id" varchar(100) NOT NULL,
+    "video_id" varchar(11) NOT NULL,
+    "minutes_ago" integer NOT NULL DEFAULT 0,
+    "url" varchar NOT NULL,
+    "schedule_time" timestamp NOT NULL,
+    "status" varchar(20) NOT NULL DEFAULT 'pending',
+    "attempts" integer NOT NULL DEFAULT 0,
+    "last_error" varchar NOT NULL DEFAULT '',
+    "created_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("name")
+);
+
+CREATE TABLE "pending_tasks" (
+    "video_id" varchar(11) NOT NULL,
+    "queue_id" varchar(100) NOT NULL,
+    "minutes_ago" integer NOT NULL,
+    "url" varchar NOT NULL,
+    "scheduled_start_time" timestamp,
+    "created_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("video_id", "queue_id", "minutes_ago")
+);
+
+CREATE TABLE "notifications" (
+    "video_id" varchar(11) NOT NULL,
+    "target" varchar(100) NOT NULL,
+    "kind" varchar(30) NOT NULL,
+    "message_id" varchar(30) NOT NULL DEFAULT '',
+    "status" varchar(20) NOT NULL,
+    "error" varchar NOT NULL DEFAULT '',
+    "video_status" varchar(20) NOT NULL DEFAULT 'upcoming',
+    "created_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("video_id", "target", "kind")
+);
+
+CREATE TABLE "notify_targets" (
+    "id" BIGSERIAL NOT NULL,
+    "scope" varchar(20) NOT NULL,
+    "name" varchar(100) NOT NULL,
+    "type" varchar(30) NOT NULL,
+    "url" varchar NOT NULL,
+    "secret" varchar NOT NULL DEFAULT '',
+    "created_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("id")
+);
+
+CREATE TABLE "song_rules" (
+    "id" BIGSERIAL NOT NULL,
+    "kind" varchar(20) NOT NULL,
+    "value" varchar NOT NULL DEFAULT '',
+    "weight" double precision NOT NULL,
+    "enabled" BOOLEAN NOT NULL DEFAULT true,
+    "created_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("id")
+);
+
+CREATE TABLE "song_labels" (
+    "video_id" varchar(11) NOT NULL,
+    "title" varchar NOT NULL,
+    "description" varchar NOT NULL DEFAULT '',
+    "tags" VARCHAR[],
+    "is_song" BOOLEAN NOT NULL,
+    "user_id" varchar(20) NOT NULL DEFAULT '',
+    "created_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("video_id")
+);
+
+CREATE TABLE "panels" (
+    "message_id" varchar(20) NOT NULL,
+    "channel_id" varchar(20) NOT NULL,
+    "created_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("message_id")
+);
+
+CREATE TABLE "reminders" (
+    "video_id" varchar(11) NOT NULL,
+    "queue_id" varchar(100) NOT NULL,
+    "minutes_ago" integer NOT NULL,
+    "url" varchar NOT NULL,
+    "schedule_time" TIMESTAMP(0) NOT NULL,
+    "status" varchar(20) NOT NULL,
+    "created_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("video_id", "queue_id", "minutes_ago")
+);
+
+CREATE TABLE "audit_logs" (
+    "id" BIGSERIAL NOT NULL,
+    "user_id" varchar(20) NOT NULL DEFAULT '',
+    "guild_id" varchar(20) NOT NULL DEFAULT '',
+    "action" varchar(100) NOT NULL,
+    "result" varchar(20) NOT NULL,
+    "reason" varchar NOT NULL DEFAULT '',
+    "created_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("id")
+);
+
+CREATE TABLE "cloud_tasks" (
+    "video_id" varchar(11) NOT NULL,
+    "queue_id" varchar(100) NOT NULL,
+    "minutes_ago" integer NOT NULL,
+    "name" varchar NOT NULL,
+    "schedule_time" TIMESTAMP(0) NOT NULL,
+    "created_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("video_id", "queue_id", "minutes_ago")
+);